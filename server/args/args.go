@@ -1,7 +1,9 @@
 package args
 
 import (
+  "time"
   "strings"
+  "strconv"
   "regexp"
 
   "github.com/mattermost/mattermost-server/v6/model"
@@ -17,31 +19,392 @@ var (
   chanNameExp = regexp.MustCompile(`(?m)^[-_a-z0-9]+$`)
   chanURLExp = regexp.MustCompile(
     `(?m)^(https?://[-_.a-z0-9]+(?::\d+)?)` +
-    `/(?:[-a-z0-9]+)/channels/([-_a-z0-9]+)$`,
+    `/([-a-z0-9]+)/channels/([-_a-z0-9]+)$`,
   )
 )
 
-func Parse(args *model.CommandArgs) ([]string, error) {
-  // Get source list
-  cmdWords := strings.Split(args.Command, " ")[1:]
-  sources := make([]string, 0, len(cmdWords))
-  for _, word := range(cmdWords) {
-    if word != "" { sources = append(sources, word) }
+const (
+  SubcommandUndo = "undo"
+  SubcommandLog = "log"
+  SubcommandNotifications = "notifications"
+  SubcommandWhere = "where"
+  SubcommandMark = "mark"
+  SubcommandBasket = "basket"
+  SubcommandHere = "here"
+  SubcommandLast = "last"
+)
+
+func Subcommand(args *model.CommandArgs) string {
+  words := getWords(args)
+  if len(words) > 0 {
+    switch words[0] {
+      case SubcommandUndo, SubcommandLog, SubcommandNotifications,
+        SubcommandWhere, SubcommandMark, SubcommandBasket,
+        SubcommandHere, SubcommandLast: return words[0]
+    }
+  }
+  return ""
+}
+
+func ParseUndo(args *model.CommandArgs) (bool, error) {
+  force := false
+  for _, word := range(getWords(args)[1:]) {
+    switch word {
+      case "--force": force = true
+      default: return false, i18n.NewError(
+        i18n.MsgErrorUnknownArgument, "Argument", word,
+      )
+    }
+  }
+  return force, nil
+}
+
+func ParseNotifications(args *model.CommandArgs) (bool, error) {
+  words := getWords(args)[1:]
+  if len(words) != 1 {
+    return false, i18n.NewError(i18n.MsgErrorOnOrOff)
+  }
+  switch words[0] {
+    case "on": return true, nil
+    case "off": return false, nil
+    default: return false, i18n.NewError(i18n.MsgErrorOnOrOff)
+  }
+}
+
+func ParseWhere(args *model.CommandArgs) (string, error) {
+  words := getWords(args)[1:]
+  if len(words) != 1 { return "", i18n.NewError(i18n.MsgErrorOneMessage) }
+  return parseMessage(args, words[0])
+}
+
+func ParseMark(args *model.CommandArgs) ([]string, error) {
+  words := getWords(args)[1:]
+  if len(words) == 0 { return nil, i18n.NewError(i18n.MsgErrorNoMessages) }
+  postIds := make([]string, 0, len(words))
+  for _, word := range(words) {
+    postId, err := parseMessage(args, word)
+    if err != nil { return nil, err }
+    postIds = append(postIds, postId)
+  }
+  return postIds, nil
+}
+
+// Whether the basket is to be cleared instead of listed
+func ParseBasket(args *model.CommandArgs) (bool, error) {
+  words := getWords(args)[1:]
+  if len(words) == 0 { return false, nil }
+  if len(words) == 1 && words[0] == "clear" { return true, nil }
+  return false, i18n.NewError(
+    i18n.MsgErrorUnknownArgument, "Argument", words[0],
+  )
+}
+
+// Parse flags of a move of the basket's messages
+func ParseHere(args *model.CommandArgs) (*Move, error) {
+  return parseMove(args, getWords(args)[1:], false)
+}
+
+// Parse "last <count> from <source>" followed by flags
+func ParseLast(args *model.CommandArgs) (*Move, error) {
+  words := getWords(args)[1:]
+  if len(words) < 3 || words[1] != "from" {
+    return nil, i18n.NewError(i18n.MsgErrorLastUsage)
+  }
+  count, err := strconv.Atoi(words[0])
+  if err != nil || count < 1 {
+    return nil, i18n.NewError(i18n.MsgErrorNotACount, "Count", words[0])
+  }
+  source, err := parseTarget(args, words[2])
+  if err != nil { return nil, err }
+  move, err := parseMove(args, words[3:], false)
+  if err != nil { return nil, err }
+  move.Last = &Last{ Count: count, Source: source }
+  return move, nil
+}
+
+type Log struct {
+  ChannelName string
+  UserName string
+  Page int
+}
+
+func ParseLog(args *model.CommandArgs) (*Log, error) {
+  log := &Log{ Page: 1 }
+  words := getWords(args)[1:]
+  for i := 0; i < len(words); i++ {
+    word := words[i]
+    switch word {
+      case "--channel", "--user", "--page":
+        if i++; i == len(words) {
+          return nil, i18n.NewError(
+            i18n.MsgErrorMissingValue, "Argument", word,
+          )
+        }
+      default: return nil, i18n.NewError(
+        i18n.MsgErrorUnknownArgument, "Argument", word,
+      )
+    }
+    value := words[i]
+    switch word {
+      case "--channel":
+        log.ChannelName = strings.TrimPrefix(value, "~")
+        if !chanNameExp.MatchString(log.ChannelName) {
+          return nil, i18n.NewError(i18n.MsgErrorNotAChannel, "Channel", value)
+        }
+      case "--user":
+        log.UserName = strings.TrimPrefix(value, "@")
+      case "--page":
+        page, err := strconv.Atoi(value)
+        if err != nil || page < 1 {
+          return nil, i18n.NewError(i18n.MsgErrorNotAPage, "Page", value)
+        }
+        log.Page = page
+    }
+  }
+  return log, nil
+}
+
+type Move struct {
+  Sources []string
+  Range *Range
+  Filter *Filter
+  Last *Last
+  Target *Target
+  Copy bool
+  Tombstone *bool
+  DryRun bool
+  Force bool
+}
+
+// Messages between two messages, both inclusive
+type Range struct {
+  First string
+  Last string
+}
+
+// Messages of a channel matching all given criteria
+type Filter struct {
+  TeamName string
+  ChannelName string
+  UserName string
+  Since time.Duration
+  Contains string
+}
+
+// Most recent messages of a channel or thread
+type Last struct {
+  Count int
+  Source *Target
+}
+
+// Explicit target, either a channel or a thread
+type Target struct {
+  TeamName string
+  ChannelName string
+  PostId string
+}
+
+func Parse(args *model.CommandArgs) (*Move, error) {
+  return parseMove(args, getWords(args), true)
+}
+
+func parseMove(
+  args *model.CommandArgs, words []string, needSources bool,
+) (*Move, error) {
+  // Separate flags from source list
+  move := &Move{}
+  sources := make([]string, 0, len(words))
+  for i := 0; i < len(words); i++ {
+    switch word := words[i]; {
+      case word == "--copy": move.Copy = true
+      case word == "--dry-run": move.DryRun = true
+      case word == "--force": move.Force = true
+      case word == "--tombstone", word == "--no-tombstone":
+        tombstone := word == "--tombstone"
+        move.Tombstone = &tombstone
+      case word == "--from", word == "--until", word == "--user",
+        word == "--since", word == "--contains":
+        if i++; i == len(words) {
+          return nil, i18n.NewError(
+            i18n.MsgErrorMissingValue, "Argument", word,
+          )
+        }
+        var err error
+        if word == "--contains" {
+          var value string
+          value, i, err = joinQuoted(words, i)
+          if err == nil { err = move.filter().setContains(value) }
+        } else {
+          err = move.parseSelector(args, word, words[i])
+        }
+        if err != nil { return nil, err }
+      case strings.Contains(word, ".."):
+        first, last, _ := strings.Cut(word, "..")
+        firstId, err := parseMessage(args, first)
+        if err != nil { return nil, err }
+        lastId, err := parseMessage(args, last)
+        if err != nil { return nil, err }
+        move.Range = &Range{ First: firstId, Last: lastId }
+      case word == "--to":
+        if i++; i == len(words) {
+          return nil, i18n.NewError(
+            i18n.MsgErrorMissingValue, "Argument", word,
+          )
+        }
+        target, err := parseTarget(args, words[i])
+        if err != nil { return nil, err }
+        move.Target = target
+      case strings.HasPrefix(word, "--"): return nil, i18n.NewError(
+        i18n.MsgErrorUnknownArgument, "Argument", word,
+      )
+      default: sources = append(sources, word)
+    }
+  }
+  if move.Range != nil && (move.Range.First == "" || move.Range.Last == "") {
+    return nil, i18n.NewError(i18n.MsgErrorIncompleteRange)
+  }
+  if !needSources && (len(sources) > 0 || move.Range != nil ||
+    move.Filter != nil) {
+    return nil, i18n.NewError(i18n.MsgErrorExtraSelection)
+  }
+  if move.Filter != nil && (len(sources) > 0 || move.Range != nil) {
+    return nil, i18n.NewError(i18n.MsgErrorMixedSelection)
+  }
+  if move.Filter != nil && move.Filter.UserName == "" &&
+    move.Filter.Since == 0 && move.Filter.Contains == "" {
+    return nil, i18n.NewError(i18n.MsgErrorNoFilter)
+  }
+  if needSources && len(sources) == 0 && move.Range == nil &&
+    move.Filter == nil {
+    return nil, i18n.NewError(i18n.MsgErrorNoMessages)
   }
-  if len(sources) == 0 { return nil, i18n.NewError(i18n.MsgErrorNoMessages) }
 
   // Extract message IDs from sources
   for i, source := range(sources) {
-    if match := msgURLExp.FindStringSubmatch(source); len(match) > 0 {
-      if match[1] != args.SiteURL {
-        return nil, i18n.NewError(i18n.MsgErrorOtherInstance)
+    postId, err := parseMessage(args, source)
+    if err != nil { return nil, err }
+    sources[i] = postId
+  }
+
+  // Return sources
+  move.Sources = sources
+  return move, nil
+}
+
+func (m *Move) filter() *Filter {
+  if m.Filter == nil { m.Filter = &Filter{} }
+  return m.Filter
+}
+
+func (m *Move) parseSelector(
+  args *model.CommandArgs, word, value string,
+) error {
+  switch word {
+    case "--from":
+      // Channels are filtered, messages start a range
+      if strings.HasPrefix(value, "~") || chanURLExp.MatchString(value) {
+        target, err := parseTarget(args, value)
+        if err != nil { return err }
+        m.filter().TeamName = target.TeamName
+        m.filter().ChannelName = target.ChannelName
+        return nil
       }
-      sources[i] = match[2]
-    } else if !msgIDExp.MatchString(source) {
-      return nil, i18n.NewError(i18n.MsgErrorNotAMessage, "PostId", source)
+      postId, err := parseMessage(args, value)
+      if err != nil { return err }
+      if m.Range == nil { m.Range = &Range{} }
+      m.Range.First = postId
+    case "--until":
+      postId, err := parseMessage(args, value)
+      if err != nil { return err }
+      if m.Range == nil { m.Range = &Range{} }
+      m.Range.Last = postId
+    case "--user":
+      m.filter().UserName = strings.TrimPrefix(value, "@")
+    case "--since":
+      since, err := parseDuration(value)
+      if err != nil { return err }
+      m.filter().Since = since
+  }
+  return nil
+}
+
+func (f *Filter) setContains(value string) error {
+  if value == "" { return i18n.NewError(i18n.MsgErrorEmptyFilter) }
+  f.Contains = value
+  return nil
+}
+
+// Parse durations like 90m, 2h or 3d
+func parseDuration(value string) (time.Duration, error) {
+  if strings.HasSuffix(value, "d") {
+    count, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+    if err == nil && count > 0 {
+      return time.Duration(count) * 24 * time.Hour, nil
     }
+  } else if duration, err := time.ParseDuration(value); err == nil {
+    if duration > 0 { return duration, nil }
   }
+  return 0, i18n.NewError(i18n.MsgErrorNotADuration, "Duration", value)
+}
 
-  // Return sources
-  return sources, nil
+// Join words enclosed in double quotes, returning the last index used
+func joinQuoted(words []string, i int) (string, int, error) {
+  if !strings.HasPrefix(words[i], "\"") { return words[i], i, nil }
+  parts := []string{ strings.TrimPrefix(words[i], "\"") }
+  for !strings.HasSuffix(parts[len(parts) - 1], "\"") {
+    if i++; i == len(words) {
+      return "", i, i18n.NewError(i18n.MsgErrorUnterminatedQuote)
+    }
+    parts = append(parts, words[i])
+  }
+  text := strings.Join(parts, " ")
+  return strings.TrimSuffix(text, "\""), i, nil
+}
+
+func parseMessage(args *model.CommandArgs, word string) (string, error) {
+  if match := msgURLExp.FindStringSubmatch(word); len(match) > 0 {
+    if match[1] != args.SiteURL {
+      return "", i18n.NewError(i18n.MsgErrorOtherInstance)
+    }
+    return match[2], nil
+  } else if !msgIDExp.MatchString(word) {
+    return "", i18n.NewError(i18n.MsgErrorNotAMessage, "PostId", word)
+  }
+  return word, nil
+}
+
+func parseTarget(args *model.CommandArgs, word string) (*Target, error) {
+  // Parse channel name
+  if strings.HasPrefix(word, "~") {
+    name := strings.TrimPrefix(word, "~")
+    if !chanNameExp.MatchString(name) {
+      return nil, i18n.NewError(i18n.MsgErrorNotATarget, "Target", word)
+    }
+    return &Target{ ChannelName: name }, nil
+  }
+
+  // Parse channel URL
+  if match := chanURLExp.FindStringSubmatch(word); len(match) > 0 {
+    if match[1] != args.SiteURL {
+      return nil, i18n.NewError(i18n.MsgErrorOtherInstance)
+    }
+    return &Target{ TeamName: match[2], ChannelName: match[3] }, nil
+  }
+
+  // Parse message URL
+  if msgURLExp.MatchString(word) {
+    postId, err := parseMessage(args, word)
+    if err != nil { return nil, err }
+    return &Target{ PostId: postId }, nil
+  }
+  return nil, i18n.NewError(i18n.MsgErrorNotATarget, "Target", word)
+}
+
+func getWords(args *model.CommandArgs) []string {
+  cmdWords := strings.Split(args.Command, " ")[1:]
+  words := make([]string, 0, len(cmdWords))
+  for _, word := range(cmdWords) {
+    if word != "" { words = append(words, word) }
+  }
+  return words
 }
@@ -0,0 +1,273 @@
+package args
+
+import (
+  "time"
+  "testing"
+  "reflect"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+const (
+  siteURL = "https://chat.example.com"
+  postA = "aaaaaaaaaaaaaaaaaaaaaaaaaa"
+  postB = "bbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+func commandArgs(command string) *model.CommandArgs {
+  return &model.CommandArgs{ Command: command, SiteURL: siteURL }
+}
+
+func assertError(t *testing.T, err error, msg *i18n.Message) {
+  t.Helper()
+  if msg == nil {
+    if err != nil { t.Fatalf("unexpected error: %v", err) }
+    return
+  }
+  if err == nil || err.Error() != msg.Other {
+    t.Fatalf("expected error %q, got %v", msg.Other, err)
+  }
+}
+
+func TestParse(t *testing.T) {
+  tombstone, noTombstone := true, false
+  tests := []struct {
+    name string
+    command string
+    move *Move
+    err *i18n.Message
+  }{
+    {
+      name: "message ids and links",
+      command: "/move " + postA + " " + siteURL + "/team/pl/" + postB,
+      move: &Move{ Sources: []string{ postA, postB } },
+    },
+    {
+      name: "copy",
+      command: "/move " + postA + " --copy",
+      move: &Move{ Sources: []string{ postA }, Copy: true },
+    },
+    {
+      name: "target channel",
+      command: "/move " + postA + " --to ~town-square",
+      move: &Move{
+        Sources: []string{ postA },
+        Target: &Target{ ChannelName: "town-square" },
+      },
+    },
+    {
+      name: "target channel link",
+      command: "/move " + postA + " --to " + siteURL + "/team/channels/dev",
+      move: &Move{
+        Sources: []string{ postA },
+        Target: &Target{ TeamName: "team", ChannelName: "dev" },
+      },
+    },
+    {
+      name: "target thread",
+      command: "/move " + postA + " --to " + siteURL + "/team/pl/" + postB,
+      move: &Move{
+        Sources: []string{ postA },
+        Target: &Target{ PostId: postB },
+      },
+    },
+    {
+      name: "invalid target",
+      command: "/move " + postA + " --to dev",
+      err: i18n.MsgErrorNotATarget,
+    },
+    {
+      name: "missing target",
+      command: "/move " + postA + " --to",
+      err: i18n.MsgErrorMissingValue,
+    },
+    {
+      name: "tombstone",
+      command: "/move " + postA + " --tombstone",
+      move: &Move{ Sources: []string{ postA }, Tombstone: &tombstone },
+    },
+    {
+      name: "no tombstone",
+      command: "/move " + postA + " --no-tombstone",
+      move: &Move{ Sources: []string{ postA }, Tombstone: &noTombstone },
+    },
+    {
+      name: "range",
+      command: "/move " + postA + ".." + postB,
+      move: &Move{
+        Sources: []string{},
+        Range: &Range{ First: postA, Last: postB },
+      },
+    },
+    {
+      name: "range from flags",
+      command: "/move --from " + postA + " --until " + postB,
+      move: &Move{
+        Sources: []string{},
+        Range: &Range{ First: postA, Last: postB },
+      },
+    },
+    {
+      name: "incomplete range",
+      command: "/move --from " + postA,
+      err: i18n.MsgErrorIncompleteRange,
+    },
+    {
+      name: "filter",
+      command: "/move --from ~dev --user @alice --since 2h " +
+        "--contains \"deploy  failed\" --dry-run",
+      move: &Move{
+        Sources: []string{},
+        Filter: &Filter{
+          ChannelName: "dev",
+          UserName: "alice",
+          Since: 2 * time.Hour,
+          Contains: "deploy failed",
+        },
+        DryRun: true,
+      },
+    },
+    {
+      name: "quoted single word",
+      command: "/move --contains \"deploy\"",
+      move: &Move{
+        Sources: []string{},
+        Filter: &Filter{ Contains: "deploy" },
+      },
+    },
+    {
+      name: "unterminated quote",
+      command: "/move --contains \"deploy --dry-run",
+      err: i18n.MsgErrorUnterminatedQuote,
+    },
+    {
+      name: "empty text",
+      command: "/move --contains \"\"",
+      err: i18n.MsgErrorEmptyFilter,
+    },
+    {
+      name: "channel without criteria",
+      command: "/move --from ~dev",
+      err: i18n.MsgErrorNoFilter,
+    },
+    {
+      name: "criteria with messages",
+      command: "/move " + postA + " --user @alice",
+      err: i18n.MsgErrorMixedSelection,
+    },
+    {
+      name: "criteria with range",
+      command: "/move --from " + postA + " --until " + postB + " --user @alice",
+      err: i18n.MsgErrorMixedSelection,
+    },
+    {
+      name: "no messages",
+      command: "/move --dry-run",
+      err: i18n.MsgErrorNoMessages,
+    },
+    {
+      name: "missing value",
+      command: "/move --user",
+      err: i18n.MsgErrorMissingValue,
+    },
+    {
+      name: "unknown argument",
+      command: "/move " + postA + " --quiet",
+      err: i18n.MsgErrorUnknownArgument,
+    },
+    {
+      name: "other instance",
+      command: "/move https://other.example.com/team/pl/" + postA,
+      err: i18n.MsgErrorOtherInstance,
+    },
+  }
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      move, err := Parse(commandArgs(test.command))
+      assertError(t, err, test.err)
+      if test.err == nil && !reflect.DeepEqual(move, test.move) {
+        t.Fatalf("expected %+v, got %+v", test.move, move)
+      }
+    })
+  }
+}
+
+func TestParseLast(t *testing.T) {
+  tests := []struct {
+    name string
+    command string
+    move *Move
+    err *i18n.Message
+  }{
+    {
+      name: "channel",
+      command: "/move last 3 from ~dev --to ~town-square",
+      move: &Move{
+        Sources: []string{},
+        Last: &Last{ Count: 3, Source: &Target{ ChannelName: "dev" } },
+        Target: &Target{ ChannelName: "town-square" },
+      },
+    },
+    {
+      name: "thread",
+      command: "/move last 2 from " + siteURL + "/team/pl/" + postA + " --copy",
+      move: &Move{
+        Sources: []string{},
+        Last: &Last{ Count: 2, Source: &Target{ PostId: postA } },
+        Copy: true,
+      },
+    },
+    {
+      name: "missing source",
+      command: "/move last 3",
+      err: i18n.MsgErrorLastUsage,
+    },
+    {
+      name: "invalid count",
+      command: "/move last 0 from ~dev",
+      err: i18n.MsgErrorNotACount,
+    },
+    {
+      name: "extra selection",
+      command: "/move last 3 from ~dev " + postA,
+      err: i18n.MsgErrorExtraSelection,
+    },
+  }
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      move, err := ParseLast(commandArgs(test.command))
+      assertError(t, err, test.err)
+      if test.err == nil && !reflect.DeepEqual(move, test.move) {
+        t.Fatalf("expected %+v, got %+v", test.move, move)
+      }
+    })
+  }
+}
+
+func TestParseDuration(t *testing.T) {
+  tests := []struct {
+    value string
+    duration time.Duration
+    ok bool
+  }{
+    { "90m", 90 * time.Minute, true },
+    { "2h", 2 * time.Hour, true },
+    { "1h30m", 90 * time.Minute, true },
+    { "3d", 72 * time.Hour, true },
+    { "0d", 0, false },
+    { "-2h", 0, false },
+    { "0s", 0, false },
+    { "d", 0, false },
+    { "yesterday", 0, false },
+  }
+  for _, test := range(tests) {
+    duration, err := parseDuration(test.value)
+    if test.ok && (err != nil || duration != test.duration) {
+      t.Errorf("%s: expected %v, got %v, %v", test.value, test.duration,
+        duration, err)
+    }
+    if !test.ok { assertError(t, err, i18n.MsgErrorNotADuration) }
+  }
+}
@@ -3,11 +3,177 @@ package i18n
 var (
   MsgCommandHint = &Message{
     ID: "command.hint",
-    Other: "[messages...]",
+    Other: "[--copy] [--dry-run] [--force] [--[no-]tombstone] [--to target] [--from message --until message] [--from ~channel] [--user @user] [--since 2h] [--contains \"text\"] [messages...|first..last] | mark [messages...] | basket [clear] | here [--copy] [--dry-run] [--force] | last n from ~channel|thread | undo [--force] | log [--channel ~channel] [--user @user] [--page n] | notifications on|off | where [message]",
   }
   MsgCommandDesc = &Message{
     ID: "command.desc",
-    Other: "Move messages (IDs or URLs) to current channel or thread",
+    Other: "Move messages (IDs or URLs) to current or given channel or thread",
+  }
+  MsgUndoSuccess = &Message{
+    ID: "undo.success",
+    Other: "Moved {{.Count}} messages back.",
+  }
+  MsgDryRunMove = &Message{
+    ID: "dry_run.move",
+    Other: "This would move {{.Posts}} messages ({{.Replies}} of them replies) " +
+      "with {{.Files}} attachments ({{.Size}}) and {{.Reactions}} reactions " +
+      "by {{.Authors}} to {{.Target}}.",
+  }
+  MsgDryRunCopy = &Message{
+    ID: "dry_run.copy",
+    Other: "This would copy {{.Posts}} messages ({{.Replies}} of them replies) " +
+      "with {{.Files}} attachments ({{.Size}}) and {{.Reactions}} reactions " +
+      "by {{.Authors}} to {{.Target}}.",
+  }
+  MsgDryRunTargetThread = &Message{
+    ID: "dry_run.target_thread",
+    Other: "[a thread]({{.Link}}) in ~{{.ChannelName}}",
+  }
+  MsgConfirmQuestion = &Message{
+    ID: "confirm.question",
+    Other: "You are about to move {{.Posts}} messages with {{.Files}} " +
+      "attachments ({{.Size}}). Do you want to continue?",
+  }
+  MsgConfirmMove = &Message{
+    ID: "confirm.move",
+    Other: "Move",
+  }
+  MsgConfirmCancel = &Message{
+    ID: "confirm.cancel",
+    Other: "Cancel",
+  }
+  MsgConfirmMoved = &Message{
+    ID: "confirm.moved",
+    Other: "The messages have been moved.",
+  }
+  MsgConfirmCanceled = &Message{
+    ID: "confirm.canceled",
+    Other: "Moving the messages has been canceled.",
+  }
+  MsgConsentQuestion = &Message{
+    ID: "consent.question",
+    Other: "@{{.UserName}} would like to move {{.Count}} messages of this conversation to {{.ChannelName}}. Everybody here has to agree.",
+  }
+  MsgConsentAllow = &Message{
+    ID: "consent.allow",
+    Other: "Agree",
+  }
+  MsgConsentDecline = &Message{
+    ID: "consent.decline",
+    Other: "Decline",
+  }
+  MsgConsentRequested = &Message{
+    ID: "consent.requested",
+    Other: "The messages will be moved once all participants of the conversation agree.",
+  }
+  MsgConsentRecorded = &Message{
+    ID: "consent.recorded",
+    Other: "Thanks, the messages will be moved once everybody agrees.",
+  }
+  MsgConsentNotAsked = &Message{
+    ID: "consent.not_asked",
+    Other: "Your consent isn't required for this move.",
+  }
+  MsgConsentDeclined = &Message{
+    ID: "consent.declined",
+    Other: "@{{.UserName}} declined moving the messages.",
+  }
+  MsgConsentMoved = &Message{
+    ID: "consent.moved",
+    Other: "Everybody agreed and the messages have been moved.",
+  }
+  MsgBasketAdded = &Message{
+    ID: "basket.added",
+    Other: "Added to your basket, which now holds {{.Count}} messages. Run `/move here` where they should go.",
+  }
+  MsgBasketContents = &Message{
+    ID: "basket.contents",
+    Other: "Your basket holds {{.Count}} messages:",
+  }
+  MsgBasketEmpty = &Message{
+    ID: "basket.empty",
+    Other: "Your basket is empty.",
+  }
+  MsgBasketCleared = &Message{
+    ID: "basket.cleared",
+    Other: "Your basket has been emptied.",
+  }
+  MsgLogEntryMove = &Message{
+    ID: "log.entry_move",
+    Other: "{{.Time}} UTC: @{{.UserName}} moved {{.Posts}} messages " +
+      "with {{.Files}} attachments from {{.From}} to {{.To}} (`{{.OperationId}}`)",
+  }
+  MsgLogEntryCopy = &Message{
+    ID: "log.entry_copy",
+    Other: "{{.Time}} UTC: @{{.UserName}} copied {{.Posts}} messages " +
+      "with {{.Files}} attachments from {{.From}} to {{.To}} (`{{.OperationId}}`)",
+  }
+  MsgLogEntryUndo = &Message{
+    ID: "log.entry_undo",
+    Other: "{{.Time}} UTC: @{{.UserName}} undid the move of {{.Posts}} messages " +
+      "with {{.Files}} attachments from {{.From}} back to {{.To}} (`{{.OperationId}}`)",
+  }
+  MsgLogEntryForced = &Message{
+    ID: "log.entry_forced",
+    Other: "(forced out of a private channel)",
+  }
+  MsgLogEmpty = &Message{
+    ID: "log.empty",
+    Other: "No moves found.",
+  }
+  MsgNotification = &Message{
+    ID: "notification",
+    Other: "@{{.UserName}} moved {{.Count}} of your messages to " +
+      "~{{.ChannelName}}. [Show messages]({{.Link}})\n\n" +
+      "You can turn these notifications off with `/move notifications off`.",
+  }
+  MsgNotificationsOn = &Message{
+    ID: "notifications.on",
+    Other: "You will be notified when others move your messages.",
+  }
+  MsgNotificationsOff = &Message{
+    ID: "notifications.off",
+    Other: "You will no longer be notified when others move your messages.",
+  }
+  MsgWhereMoved = &Message{
+    ID: "where.moved",
+    Other: "The message has been moved [here]({{.Link}}).",
+  }
+  MsgWhereNotMoved = &Message{
+    ID: "where.not_moved",
+    Other: "The message has not been moved.",
+  }
+  MsgWhereNoAccess = &Message{
+    ID: "where.no_access",
+    Other: "The message has been moved somewhere you don't have access to.",
+  }
+  MsgWhereGone = &Message{
+    ID: "where.gone",
+    Other: "The message {{.PostId}} doesn't exist anymore.",
+  }
+  MsgTombstone = &Message{
+    ID: "tombstone",
+    Other: "{{.Count}} messages were [moved]({{.Link}}) to ~{{.ChannelName}} by @{{.UserName}}.",
+  }
+  MsgRewriteFailed = &Message{
+    ID: "rewrite_failed",
+    Other: "{{.Count}} messages linking to the messages you moved couldn't be updated and still contain the old links.",
+  }
+  MsgJobStarted = &Message{
+    ID: "job.started",
+    Other: "Moving the messages in the background…",
+  }
+  MsgJobProgress = &Message{
+    ID: "job.progress",
+    Other: "Moving the messages in the background: {{.Done}} of {{.Total}} copied…",
+  }
+  MsgJobDone = &Message{
+    ID: "job.done",
+    Other: "{{.Count}} messages have been moved.",
+  }
+  MsgWarningAuthorsNotInTeam = &Message{
+    ID: "warning.authors_not_in_team",
+    Other: "{{.Users}} can't see their moved messages since they aren't members of the team {{.TeamName}}.",
   }
   MsgErrorServer = &Message{
     ID: "error.server",
@@ -17,6 +183,54 @@ var (
     ID: "error.no_messages",
     Other: "You didn't specify any messages.",
   }
+  MsgErrorIncompleteRange = &Message{
+    ID: "error.incomplete_range",
+    Other: "Please specify both the first and the last message of the range.",
+  }
+  MsgErrorRangeChannels = &Message{
+    ID: "error.range_channels",
+    Other: "The first and the last message of the range have to be in the same channel.",
+  }
+  MsgErrorNotADuration = &Message{
+    ID: "error.not_a_duration",
+    Other: "{{.Duration}} is no duration like 30m, 2h or 3d.",
+  }
+  MsgErrorEmptyFilter = &Message{
+    ID: "error.empty_filter",
+    Other: "Please specify the text to look for.",
+  }
+  MsgErrorUnterminatedQuote = &Message{
+    ID: "error.unterminated_quote",
+    Other: "The quoted text is missing its closing quote.",
+  }
+  MsgErrorMixedSelection = &Message{
+    ID: "error.mixed_selection",
+    Other: "Criteria can't be combined with message links or ranges.",
+  }
+  MsgErrorNoFilter = &Message{
+    ID: "error.no_filter",
+    Other: "Please narrow the selection down with `--user`, `--since` or `--contains`.",
+  }
+  MsgErrorNoMatches = &Message{
+    ID: "error.no_matches",
+    Other: "No messages match the given criteria.",
+  }
+  MsgErrorBasketEmpty = &Message{
+    ID: "error.basket_empty",
+    Other: "Your basket is empty. Add messages with `/move mark` first.",
+  }
+  MsgErrorExtraSelection = &Message{
+    ID: "error.extra_selection",
+    Other: "This command selects the messages itself and takes no others.",
+  }
+  MsgErrorNotACount = &Message{
+    ID: "error.not_a_count",
+    Other: "{{.Count}} is no positive number of messages.",
+  }
+  MsgErrorLastUsage = &Message{
+    ID: "error.last_usage",
+    Other: "Usage: `/move last <number> from <~channel or thread link>`",
+  }
   MsgErrorOtherInstance = &Message{
     ID: "error.other_instance",
     Other: "Cannot move messages from other Mattermost.",
@@ -49,12 +263,116 @@ var (
     ID: "error.other_team",
     Other: "Can't move messages between teams.",
   }
-  MsgErrorPrivateChannel = &Message{
-    ID: "error.private_channel",
-    Other: "Can't move messages out of private channels.",
+  MsgErrorTeamMember = &Message{
+    ID: "error.team_member",
+    Other: "You aren't a member of the team {{.TeamName}}.",
+  }
+  MsgErrorAuthorsNotInTeam = &Message{
+    ID: "error.authors_not_in_team",
+    Other: "{{.Users}} aren't members of the team {{.TeamName}}.",
+  }
+  MsgErrorConversationAudience = &Message{
+    ID: "error.conversation_audience",
+    Other: "{{.Users}} would gain access to messages they can't see yet.",
+  }
+  MsgErrorPrivateAudience = &Message{
+    ID: "error.private_audience",
+    Other: "{{.Users}} would gain access to messages of a private channel. Channel admins can append --force to move them anyway.",
+  }
+  MsgErrorPrivateToPublic = &Message{
+    ID: "error.private_to_public",
+    Other: "Can't move messages from a private into a public channel. Channel admins can append --force to move them anyway.",
   }
   MsgErrorPermissionTarget = &Message{
     ID: "error.permission_target",
     Other: "You are not allowed to create messages in channel {{.ChannelName}}.",
   }
+  MsgErrorMoveFailed = &Message{
+    ID: "error.move_failed",
+    Other: "Moving the messages failed. All changes have been reverted.",
+  }
+  MsgErrorMoveIncomplete = &Message{
+    ID: "error.move_incomplete",
+    Other: "Not all messages could be moved. The remaining ones were left in place.",
+  }
+  MsgErrorAlreadyMoved = &Message{
+    ID: "error.already_moved",
+    Other: "These messages have just been moved already.",
+  }
+  MsgErrorAlreadyCopied = &Message{
+    ID: "error.already_copied",
+    Other: "These messages have just been copied already.",
+  }
+  MsgErrorAlreadyMoving = &Message{
+    ID: "error.already_moving",
+    Other: "These messages are already being moved. Please try again later.",
+  }
+  MsgErrorUnknownArgument = &Message{
+    ID: "error.unknown_argument",
+    Other: "Unknown argument {{.Argument}}.",
+  }
+  MsgErrorNothingToUndo = &Message{
+    ID: "error.nothing_to_undo",
+    Other: "There is no move to undo.",
+  }
+  MsgErrorUndoExpired = &Message{
+    ID: "error.undo_expired",
+    Other: "Moves can only be undone within {{.Minutes}} minutes.",
+  }
+  MsgErrorUndoNewReplies = &Message{
+    ID: "error.undo_new_replies",
+    Other: "The moved messages received new replies. Use --force to undo anyway.",
+  }
+  MsgErrorPermissionCopy = &Message{
+    ID: "error.permission_copy",
+    Other: "You are not allowed to copy message {{.PostId}}.",
+  }
+  MsgErrorMissingValue = &Message{
+    ID: "error.missing_value",
+    Other: "Argument {{.Argument}} requires a value.",
+  }
+  MsgErrorNotATarget = &Message{
+    ID: "error.not_a_target",
+    Other: "{{.Target}} is not a channel name, channel URL or message URL.",
+  }
+  MsgErrorChannelNotExist = &Message{
+    ID: "error.channel_not_exist",
+    Other: "Channel {{.ChannelName}} doesn't exist.",
+  }
+  MsgErrorPermissionRole = &Message{
+    ID: "error.permission_role",
+    Other: "Your role is not allowed to move messages.",
+  }
+  MsgErrorTooManyPosts = &Message{
+    ID: "error.too_many_posts",
+    Other: "Can't move more than {{.Max}} messages at once.",
+  }
+  MsgErrorConfirmExpired = &Message{
+    ID: "error.confirm_expired",
+    Other: "The confirmation has expired. Please run the command again.",
+  }
+  MsgErrorNotAChannel = &Message{
+    ID: "error.not_a_channel",
+    Other: "{{.Channel}} is not a channel name.",
+  }
+  MsgErrorNotAPage = &Message{
+    ID: "error.not_a_page",
+    Other: "{{.Page}} is not a page number.",
+  }
+  MsgErrorUserNotExist = &Message{
+    ID: "error.user_not_exist",
+    Other: "User {{.UserName}} doesn't exist.",
+  }
+  MsgErrorPermissionLog = &Message{
+    ID: "error.permission_log",
+    Other: "Only system admins and admins of the given channel may view the log.",
+  }
+  MsgErrorOnOrOff = &Message{
+    ID: "error.on_or_off",
+    Other: "Please specify either on or off.",
+  }
+  MsgErrorOneMessage = &Message{
+    ID: "error.one_message",
+    Other: "Please specify exactly one message.",
+  }
 )
@@ -0,0 +1,223 @@
+package plug
+
+import (
+  "fmt"
+  "math"
+  "time"
+  "strings"
+  "strconv"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const (
+  auditKeyPrefix = "audit_"
+  auditPageSize = 10
+)
+
+type auditEntry struct {
+  OperationId string `json:"operation_id"`
+  UserId string `json:"user_id"`
+  Timestamp int64 `json:"timestamp"`
+  Copy bool `json:"copy"`
+  TargetChannel string `json:"target_channel"`
+  TargetThread string `json:"target_thread"`
+  Sources []auditSource `json:"sources"`
+  Files int `json:"files"`
+  Forced bool `json:"forced,omitempty"`
+  Undo bool `json:"undo,omitempty"`
+}
+
+type auditSource struct {
+  FromChannel string `json:"from_channel"`
+  FromThread string `json:"from_thread"`
+  Posts []postMapping `json:"posts"`
+}
+
+type postMapping struct {
+  Old string `json:"old"`
+  New string `json:"new"`
+}
+
+// Keys sort newest first
+func auditKey(entry *auditEntry) string {
+  return fmt.Sprintf(
+    "%s%019d_%s", auditKeyPrefix,
+    math.MaxInt64 - entry.Timestamp, entry.OperationId,
+  )
+}
+
+func (p *Plug) saveAuditEntry(plan *movePlan, txs []*transaction) error {
+  entry := &auditEntry{
+    OperationId: plan.operationId,
+    UserId: plan.userId,
+    Timestamp: model.GetMillis(),
+    Copy: plan.move.Copy,
+    Forced: plan.forced,
+    TargetChannel: plan.tgtChannel.Id,
+    Sources: make([]auditSource, 0, len(plan.srcPosts)),
+  }
+  if plan.tgtPost != nil { entry.TargetThread = plan.tgtPost.Id }
+  for i, source := range(plan.srcPosts) {
+    mappings := make([]postMapping, 0, len(plan.posts[i]))
+    for j, post := range(plan.posts[i]) {
+      newId := txs[i].posts[j].Id
+      mappings = append(mappings, postMapping{ Old: post.Id, New: newId })
+      entry.Files += len(post.FileIds)
+    }
+    entry.Sources = append(entry.Sources, auditSource{
+      FromChannel: source.ChannelId,
+      FromThread: source.RootId,
+      Posts: mappings,
+    })
+  }
+  return p.storeAuditEntry(entry)
+}
+
+// Record messages moved back to where they came from by an undo
+func (p *Plug) saveUndoAuditEntry(
+  operationId, userId string, posts []*model.Post, tx *transaction,
+  channel *model.Channel, root *model.Post,
+) error {
+  entry := &auditEntry{
+    OperationId: operationId,
+    UserId: userId,
+    Timestamp: model.GetMillis(),
+    TargetChannel: channel.Id,
+    Undo: true,
+  }
+  if root != nil { entry.TargetThread = root.Id }
+  mappings := make([]postMapping, 0, len(posts))
+  for i, post := range(posts) {
+    newId := tx.posts[i].Id
+    mappings = append(mappings, postMapping{ Old: post.Id, New: newId })
+    entry.Files += len(post.FileIds)
+  }
+  entry.Sources = []auditSource{{
+    FromChannel: posts[0].ChannelId,
+    FromThread: posts[0].RootId,
+    Posts: mappings,
+  }}
+  return p.storeAuditEntry(entry)
+}
+
+func (p *Plug) storeAuditEntry(entry *auditEntry) error {
+  key := auditKey(entry)
+  _, err := p.api.KV.Set(key, entry)
+  if err != nil { return err }
+  return p.addToIndex(auditIndexKey, auditKeyPrefix, key)
+}
+
+func (p *Plug) runAuditLog(
+  teamId, userId string, log *args.Log,
+) (string, error) {
+  p.api.Log.Debug("Running log command", "user", userId, "log", log)
+  localizer := p.i18n.User(userId)
+
+  // Resolve filters
+  var channelId, filterUserId string
+  if log.ChannelName != "" {
+    channel, err := p.api.Channel.GetByName(teamId, log.ChannelName, false)
+    if err != nil {
+      return "", i18n.NewError(
+        i18n.MsgErrorChannelNotExist, "ChannelName", log.ChannelName,
+      )
+    }
+    channelId = channel.Id
+  }
+  if log.UserName != "" {
+    user, err := p.api.User.GetByUsername(log.UserName)
+    if err != nil {
+      return "", i18n.NewError(
+        i18n.MsgErrorUserNotExist, "UserName", log.UserName,
+      )
+    }
+    filterUserId = user.Id
+  }
+
+  // Check permissions
+  isAdmin := p.api.User.HasPermissionTo(userId, model.PermissionManageSystem)
+  if !isAdmin && (channelId == "" || !p.api.User.HasPermissionToChannel(
+    userId, channelId, model.PermissionManageChannelRoles,
+  )) {
+    return "", i18n.NewError(i18n.MsgErrorPermissionLog)
+  }
+
+  // Collect matching entries
+  keys, err := p.getIndex(auditIndexKey, auditKeyPrefix)
+  if err != nil { return "", err }
+  skip := (log.Page - 1) * auditPageSize
+  lines := make([]string, 0, auditPageSize)
+  for _, key := range(keys) {
+    var entry *auditEntry
+    err := p.api.KV.Get(key, &entry)
+    if err != nil { return "", err }
+    if entry == nil || !entry.matches(channelId, filterUserId) { continue }
+    if skip > 0 { skip--; continue }
+    line, err := p.formatAuditEntry(entry, localizer)
+    if err != nil { return "", err }
+    lines = append(lines, line)
+    if len(lines) == auditPageSize { break }
+  }
+  if len(lines) == 0 { return localizer.Static(i18n.MsgLogEmpty), nil }
+  return strings.Join(lines, "\n"), nil
+}
+
+func (e *auditEntry) matches(channelId, userId string) bool {
+  if userId != "" && e.UserId != userId { return false }
+  if channelId == "" || e.TargetChannel == channelId { return true }
+  for _, source := range(e.Sources) {
+    if source.FromChannel == channelId { return true }
+  }
+  return false
+}
+
+func (p *Plug) formatAuditEntry(
+  entry *auditEntry, localizer *i18n.Localizer,
+) (string, error) {
+  // Collect names
+  user, err := p.api.User.Get(entry.UserId)
+  if err != nil { return "", err }
+  channelNames := make([]string, 0, len(entry.Sources))
+  posts := 0
+  for _, source := range(entry.Sources) {
+    name := p.getChannelMention(source.FromChannel)
+    if !containsString(channelNames, name) {
+      channelNames = append(channelNames, name)
+    }
+    posts += len(source.Posts)
+  }
+
+  // Format entry
+  msg := i18n.MsgLogEntryMove
+  if entry.Copy { msg = i18n.MsgLogEntryCopy }
+  if entry.Undo { msg = i18n.MsgLogEntryUndo }
+  var forced string
+  if entry.Forced { forced = " " + localizer.Static(i18n.MsgLogEntryForced) }
+  return "- " + localizer.Template(msg, map[string]string{
+    "Time": time.UnixMilli(entry.Timestamp).UTC().Format("2006-01-02 15:04"),
+    "UserName": user.Username,
+    "Posts": strconv.Itoa(posts),
+    "Files": strconv.Itoa(entry.Files),
+    "From": strings.Join(channelNames, ", "),
+    "To": p.getChannelMention(entry.TargetChannel),
+    "OperationId": entry.OperationId,
+  }) + forced, nil
+}
+
+// Mention channel by name, falling back to its ID if it is gone
+func (p *Plug) getChannelMention(channelId string) string {
+  channel, err := p.api.Channel.Get(channelId)
+  if err != nil { return channelId }
+  return "~" + channel.Name
+}
+
+func containsString(list []string, item string) bool {
+  for _, other := range(list) {
+    if other == item { return true }
+  }
+  return false
+}
@@ -0,0 +1,212 @@
+package plug
+
+import (
+  "strconv"
+  "strings"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+  "github.com/mattermost/mattermost-server/v6/plugin"
+  "github.com/mattermost/mattermost-plugin-api/cluster"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+// Messages collected by a user for moving them later
+type basket struct {
+  PostIds []string `json:"post_ids"`
+}
+
+func basketKey(userId string) string {
+  return "basket_" + userId
+}
+
+func (p *Plug) getBasket(userId string) (*basket, error) {
+  var b *basket
+  err := p.api.KV.Get(basketKey(userId), &b)
+  if err != nil { return nil, err }
+  if b == nil { b = &basket{ PostIds: []string{} } }
+  return b, nil
+}
+
+// Add messages to the basket, returning its new size
+func (p *Plug) addToBasket(userId string, postIds []string) (int, error) {
+  var count int
+  err := p.updateBasket(userId, func(b *basket) {
+    for _, postId := range(postIds) {
+      if !containsString(b.PostIds, postId) {
+        b.PostIds = append(b.PostIds, postId)
+      }
+    }
+    count = len(b.PostIds)
+  })
+  return count, err
+}
+
+// Change the basket across the cluster, deleting it once empty
+func (p *Plug) updateBasket(userId string, update func(*basket)) error {
+  mutex, err := cluster.NewMutex(p.API, basketKey(userId))
+  if err != nil { return err }
+  mutex.Lock()
+  defer mutex.Unlock()
+
+  b, err := p.getBasket(userId)
+  if err != nil { return err }
+  update(b)
+  if len(b.PostIds) == 0 { return p.api.KV.Delete(basketKey(userId)) }
+  expiry := p.getConfiguration().basketExpiry()
+  return p.api.KV.SetWithExpiry(basketKey(userId), b, expiry)
+}
+
+// Take moved messages out of the basket, keeping ones added meanwhile
+func (p *Plug) removeFromBasket(userId string, postIds []string) {
+  if len(postIds) == 0 { return }
+  err := p.updateBasket(userId, func(b *basket) {
+    kept := make([]string, 0, len(b.PostIds))
+    for _, postId := range(b.PostIds) {
+      if !containsString(postIds, postId) { kept = append(kept, postId) }
+    }
+    b.PostIds = kept
+  })
+  if err != nil {
+    p.api.Log.Warn("Failed to clear basket", "error", err.Error())
+  }
+}
+
+// Collect messages the user reacted to with the basket emoji
+func (p *Plug) ReactionHasBeenAdded(
+  c *plugin.Context, reaction *model.Reaction,
+) {
+  emoji := p.getConfiguration().BasketEmoji
+  if emoji == "" || reaction.EmojiName != emoji { return }
+  if reaction.UserId == p.botId { return }
+
+  // Add message and remove reaction
+  count, err := p.addToBasket(reaction.UserId, []string{ reaction.PostId })
+  if err != nil {
+    p.api.Log.Error("Failed to add message to basket", "error", err.Error())
+    return
+  }
+  err = p.api.Post.RemoveReaction(reaction)
+  if err != nil {
+    p.api.Log.Warn("Failed to remove basket reaction", "error", err.Error())
+  }
+
+  // Acknowledge addition
+  post, err := p.api.Post.GetPost(reaction.PostId)
+  if err != nil { return }
+  localizer := p.i18n.User(reaction.UserId)
+  p.api.Post.SendEphemeralPost(reaction.UserId, &model.Post{
+    UserId: p.botId,
+    ChannelId: post.ChannelId,
+    RootId: post.RootId,
+    Message: localizer.Template(i18n.MsgBasketAdded, map[string]string{
+      "Count": strconv.Itoa(count),
+    }),
+  })
+}
+
+func (p *Plug) executeMark(cmd *model.CommandArgs) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  postIds, err := args.ParseMark(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Check messages
+  posts, err := p.getPostsFromIds(postIds)
+  if err != nil { return p.responseFromError(err, localizer) }
+  for _, post := range(posts) {
+    if !p.api.User.HasPermissionToChannel(
+      cmd.UserId, post.ChannelId, model.PermissionReadChannel,
+    ) {
+      return p.responseFromError(
+        i18n.NewError(i18n.MsgErrorNotExist, "PostId", post.Id), localizer,
+      )
+    }
+  }
+
+  // Add messages
+  count, err := p.addToBasket(cmd.UserId, postIds)
+  if err != nil { return p.responseFromError(err, localizer) }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: localizer.Template(i18n.MsgBasketAdded, map[string]string{
+      "Count": strconv.Itoa(count),
+    }),
+  }
+}
+
+func (p *Plug) executeBasket(cmd *model.CommandArgs) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  clear, err := args.ParseBasket(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Clear basket
+  if clear {
+    err = p.api.KV.Delete(basketKey(cmd.UserId))
+    if err != nil { return p.responseFromError(err, localizer) }
+    return &model.CommandResponse{
+      ResponseType: model.CommandResponseTypeEphemeral,
+      Text: localizer.Static(i18n.MsgBasketCleared),
+    }
+  }
+
+  // List messages
+  b, err := p.getBasket(cmd.UserId)
+  if err != nil { return p.responseFromError(err, localizer) }
+  lines := make([]string, 0, len(b.PostIds))
+  for _, postId := range(b.PostIds) {
+    post, err := p.api.Post.GetPost(postId)
+    if err != nil { continue }
+    link, err := p.getPermalink(post)
+    if err != nil { return p.responseFromError(err, localizer) }
+    lines = append(lines, "- " + link)
+  }
+  text := localizer.Static(i18n.MsgBasketEmpty)
+  if len(lines) > 0 {
+    text = localizer.Template(i18n.MsgBasketContents, map[string]string{
+      "Count": strconv.Itoa(len(lines)),
+    }) + "\n" + strings.Join(lines, "\n")
+  }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: text,
+  }
+}
+
+func (p *Plug) executeHere(cmd *model.CommandArgs) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  move, err := args.ParseHere(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Take messages still existing from basket
+  b, err := p.getBasket(cmd.UserId)
+  if err != nil { return p.responseFromError(err, localizer) }
+  posts := make([]*model.Post, 0, len(b.PostIds))
+  postIds := make([]string, 0, len(b.PostIds))
+  for _, postId := range(b.PostIds) {
+    post, err := p.api.Post.GetPost(postId)
+    if err != nil { continue }
+    posts = append(posts, post)
+    postIds = append(postIds, postId)
+  }
+  if len(posts) == 0 {
+    return p.responseFromError(
+      i18n.NewError(i18n.MsgErrorBasketEmpty), localizer,
+    )
+  }
+
+  // Leave replies to their moved roots
+  for _, post := range(posts) {
+    if post.RootId != "" && containsString(postIds, post.RootId) { continue }
+    move.Sources = append(move.Sources, post.Id)
+  }
+
+  // Move messages, emptying the basket once they are moved
+  return p.runMoveCommand(cmd, move, postIds)
+}
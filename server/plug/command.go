@@ -2,6 +2,7 @@ package plug
 
 import (
   "errors"
+  "strconv"
 
   "github.com/mattermost/mattermost-server/v6/model"
   "github.com/mattermost/mattermost-server/v6/plugin"
@@ -22,23 +23,254 @@ func (p *Plug) createCommand(trigger string) *model.Command {
 func (p *Plug) ExecuteCommand(
   c *plugin.Context, cmd *model.CommandArgs,
 ) (*model.CommandResponse, *model.AppError) {
+  switch args.Subcommand(cmd) {
+    case args.SubcommandUndo: return p.executeUndo(cmd), nil
+    case args.SubcommandLog: return p.executeLog(cmd), nil
+    case args.SubcommandNotifications:
+      return p.executeNotifications(cmd), nil
+    case args.SubcommandWhere: return p.executeWhere(cmd), nil
+    case args.SubcommandMark: return p.executeMark(cmd), nil
+    case args.SubcommandBasket: return p.executeBasket(cmd), nil
+    case args.SubcommandHere: return p.executeHere(cmd), nil
+    case args.SubcommandLast: return p.executeLast(cmd), nil
+    default: return p.executeMove(cmd), nil
+  }
+}
+
+func (p *Plug) executeMove(cmd *model.CommandArgs) *model.CommandResponse {
+  // Parse args
+  move, err := args.Parse(cmd)
+  if err != nil {
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
+  }
+  return p.runMoveCommand(cmd, move, nil)
+}
+
+func (p *Plug) executeLast(cmd *model.CommandArgs) *model.CommandResponse {
   // Parse args
-  sourceIds, err := args.Parse(cmd)
+  move, err := args.ParseLast(cmd)
+  if err != nil {
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
+  }
+  return p.runMoveCommand(cmd, move, nil)
+}
+
+// Run a parsed move, taking the given messages out of the basket once moved
+func (p *Plug) runMoveCommand(
+  cmd *model.CommandArgs, move *args.Move, basketPostIds []string,
+) *model.CommandResponse {
+  // Resolve selection and target
+  err := p.resolveRange(move)
+  if err == nil { err = p.resolveFilter(cmd.TeamId, cmd.ChannelId, move) }
+  if err == nil { err = p.resolveLast(cmd.TeamId, move) }
+  if err != nil {
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
+  }
+  channelId, rootId := cmd.ChannelId, cmd.RootId
+  if move.Target != nil {
+    channelId, rootId, err = p.resolveTarget(cmd.TeamId, move.Target)
+    if err != nil {
+      return p.responseFromError(err, p.i18n.User(cmd.UserId))
+    }
+  }
+
+  // Plan move
+  plan, err := p.planMove(cmd.TeamId, channelId, rootId, cmd.UserId, move)
   if err != nil {
-    return p.responseFromError(err, p.i18n.User(cmd.UserId)), nil
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
+  }
+  plan.basketPostIds = basketPostIds
+
+  // Summarize move for dry runs
+  if move.DryRun {
+    summary, err := p.summarizeMovePlan(plan, p.i18n.User(cmd.UserId))
+    if err == nil {
+      var warning string
+      warning, err = p.crossTeamWarning(plan, p.i18n.User(cmd.UserId))
+      if warning != "" { summary += "\n\n" + warning }
+    }
+    if err != nil {
+      return p.responseFromError(err, p.i18n.User(cmd.UserId))
+    }
+    return &model.CommandResponse{
+      ResponseType: model.CommandResponseTypeEphemeral,
+      Text: summary,
+    }
+  }
+
+  // Ask participants before moving conversations
+  pending := &pendingMove{
+    TeamId: cmd.TeamId, ChannelId: channelId, RootId: rootId,
+    UserId: cmd.UserId, Move: move,
+    CommandChannelId: cmd.ChannelId, CommandRootId: cmd.RootId,
+    BasketPostIds: basketPostIds,
+  }
+  if len(plan.consentUserIds) > 0 {
+    response, err := p.requestConsent(pending, plan)
+    if err != nil {
+      return p.responseFromError(err, p.i18n.User(cmd.UserId))
+    }
+    return response
+  }
+
+  // Ask for confirmation of large moves
+  confirm, err := p.needsConfirmation(plan)
+  if err != nil {
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
+  }
+  if confirm {
+    response, err := p.requestConfirmation(pending, plan)
+    if err != nil {
+      return p.responseFromError(err, p.i18n.User(cmd.UserId))
+    }
+    return response
   }
 
   // Move messages
-  err = p.runMoveMessages(
-    cmd.TeamId, cmd.ChannelId, cmd.RootId, cmd.UserId, sourceIds,
-  )
+  _, err = p.executePlan(plan, cmd.ChannelId, cmd.RootId)
   if err != nil {
-    return p.responseFromError(err, p.i18n.User(cmd.UserId)), nil
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
   }
 
   // Return successfully
   p.api.Log.Debug("Messages moved successfully")
-  return &model.CommandResponse{}, nil
+  warning, err := p.crossTeamWarning(plan, p.i18n.User(cmd.UserId))
+  if err != nil {
+    return p.responseFromError(err, p.i18n.User(cmd.UserId))
+  }
+  if warning != "" {
+    return &model.CommandResponse{
+      ResponseType: model.CommandResponseTypeEphemeral,
+      Text: warning,
+    }
+  }
+  return &model.CommandResponse{}
+}
+
+func (p *Plug) resolveTarget(
+  teamId string, target *args.Target,
+) (string, string, error) {
+  // Resolve thread
+  if target.PostId != "" {
+    post, err := p.api.Post.GetPost(target.PostId)
+    if err != nil {
+      return "", "", i18n.NewError(
+        i18n.MsgErrorNotExist, "PostId", target.PostId,
+      )
+    }
+    if post.RootId != "" { return post.ChannelId, post.RootId, nil }
+    return post.ChannelId, post.Id, nil
+  }
+
+  // Resolve channel
+  var channel *model.Channel
+  var err error
+  if target.TeamName != "" {
+    channel, err = p.api.Channel.GetByNameForTeamName(
+      target.TeamName, target.ChannelName, false,
+    )
+  } else {
+    channel, err = p.api.Channel.GetByName(teamId, target.ChannelName, false)
+  }
+  if err != nil {
+    return "", "", i18n.NewError(
+      i18n.MsgErrorChannelNotExist, "ChannelName", target.ChannelName,
+    )
+  }
+  return channel.Id, "", nil
+}
+
+func (p *Plug) executeUndo(cmd *model.CommandArgs) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  force, err := args.ParseUndo(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Undo move
+  count, err := p.runUndo(cmd.UserId, force)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Return successfully
+  p.api.Log.Debug("Move undone successfully")
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: localizer.Template(i18n.MsgUndoSuccess, map[string]string{
+      "Count": strconv.Itoa(count),
+    }),
+  }
+}
+
+func (p *Plug) executeLog(cmd *model.CommandArgs) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  log, err := args.ParseLog(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // List audit entries
+  text, err := p.runAuditLog(cmd.TeamId, cmd.UserId, log)
+  if err != nil { return p.responseFromError(err, localizer) }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: text,
+  }
+}
+
+func (p *Plug) executeNotifications(
+  cmd *model.CommandArgs,
+) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  enabled, err := args.ParseNotifications(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Store preference
+  err = p.setNotificationsEnabled(cmd.UserId, enabled)
+  if err != nil { return p.responseFromError(err, localizer) }
+  msg := i18n.MsgNotificationsOff
+  if enabled { msg = i18n.MsgNotificationsOn }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: localizer.Static(msg),
+  }
+}
+
+func (p *Plug) executeWhere(cmd *model.CommandArgs) *model.CommandResponse {
+  localizer := p.i18n.User(cmd.UserId)
+
+  // Parse args
+  postId, err := args.ParseWhere(cmd)
+  if err != nil { return p.responseFromError(err, localizer) }
+
+  // Resolve current location
+  newId, moved, err := p.resolvePostId(postId)
+  if err != nil { return p.responseFromError(err, localizer) }
+  post, err := p.api.Post.GetPost(newId)
+  var text string
+  switch {
+    case err != nil:
+      text = localizer.Template(i18n.MsgWhereGone, map[string]string{
+        "PostId": postId,
+      })
+    case !moved:
+      text = localizer.Static(i18n.MsgWhereNotMoved)
+    case !p.api.User.HasPermissionToChannel(
+      cmd.UserId, post.ChannelId, model.PermissionReadChannel,
+    ):
+      text = localizer.Static(i18n.MsgWhereNoAccess)
+    default:
+      link, err := p.getPermalink(post)
+      if err != nil { return p.responseFromError(err, localizer) }
+      text = localizer.Template(i18n.MsgWhereMoved, map[string]string{
+        "Link": link,
+      })
+  }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: text,
+  }
 }
 
 func (p *Plug) responseFromError(
@@ -0,0 +1,120 @@
+package plug
+
+import (
+  "time"
+  "errors"
+  "strings"
+)
+
+type configuration struct {
+  UndoWindow int
+  AllowCrossTeam bool
+  CrossTeamAuthors string
+  AllowPrivateChannels bool
+  MaxPosts int
+  ConfirmPosts int
+  ConfirmSize int
+  EnableTombstones bool
+  TombstoneTeams string
+  EnableAuthorNotifications bool
+  AllowedRoles string
+  RewriteLinks string
+  SuppressNotifications bool
+  Concurrency int
+  StepTimeout int
+  BackgroundPosts int
+  BasketEmoji string
+  BasketExpiry int
+}
+
+func (c *configuration) validate() error {
+  if c.UndoWindow < 0 { return errors.New("undo window must not be negative") }
+  if c.MaxPosts < 0 { return errors.New("max posts must not be negative") }
+  if c.ConfirmPosts < 0 || c.ConfirmSize < 0 {
+    return errors.New("confirmation thresholds must not be negative")
+  }
+  if c.Concurrency < 0 || c.StepTimeout < 0 {
+    return errors.New("concurrency and step timeout must not be negative")
+  }
+  if c.BasketExpiry < 0 {
+    return errors.New("basket expiry must not be negative")
+  }
+  if c.BackgroundPosts < 0 {
+    return errors.New("background job threshold must not be negative")
+  }
+  switch c.CrossTeamAuthors {
+    case "", crossTeamAuthorsRequire, crossTeamAuthorsAdd, crossTeamAuthorsWarn:
+    default: return errors.New("invalid cross-team author handling")
+  }
+  switch c.RewriteLinks {
+    case "", rewriteLinksOff, rewriteLinksChannels, rewriteLinksTeam:
+    default: return errors.New("invalid link rewriting mode")
+  }
+  return nil
+}
+
+func (c *configuration) undoWindow() time.Duration {
+  return time.Duration(c.UndoWindow) * time.Minute
+}
+
+// Time after which unused baskets are emptied
+func (c *configuration) basketExpiry() time.Duration {
+  if c.BasketExpiry == 0 { return 24 * time.Hour }
+  return time.Duration(c.BasketExpiry) * time.Minute
+}
+
+func (c *configuration) stepTimeout() time.Duration {
+  return time.Duration(c.StepTimeout) * time.Second
+}
+
+// Attachment size in bytes above which moves need confirmation
+func (c *configuration) confirmSize() int64 {
+  return int64(c.ConfirmSize) * 1024 * 1024
+}
+
+// Whether to leave tombstones in the team unless specified otherwise
+func (c *configuration) tombstoneDefault(teamName string) bool {
+  if !c.EnableTombstones { return false }
+  if strings.TrimSpace(c.TombstoneTeams) == "" { return true }
+  for _, name := range(splitList(c.TombstoneTeams)) {
+    if name == teamName { return true }
+  }
+  return false
+}
+
+// Roles allowed to move messages, nil if unrestricted
+func (c *configuration) allowedRoles() []string {
+  roles := splitList(c.AllowedRoles)
+  if len(roles) == 0 { return nil }
+  return roles
+}
+
+func (p *Plug) getConfiguration() *configuration {
+  p.configLock.RLock()
+  defer p.configLock.RUnlock()
+  if p.config == nil { return &configuration{} }
+  return p.config
+}
+
+// Load configuration (called before activation as well)
+func (p *Plug) OnConfigurationChange() error {
+  config := &configuration{}
+  err := p.API.LoadPluginConfiguration(config)
+  if err != nil { return err }
+  err = config.validate()
+  if err != nil { return err }
+
+  p.configLock.Lock()
+  defer p.configLock.Unlock()
+  p.config = config
+  return nil
+}
+
+func splitList(list string) []string {
+  items := make([]string, 0)
+  for _, item := range(strings.Split(list, ",")) {
+    item = strings.TrimSpace(item)
+    if item != "" { items = append(items, item) }
+  }
+  return items
+}
@@ -0,0 +1,138 @@
+package plug
+
+import (
+  "time"
+  "strconv"
+  "net/http"
+  "encoding/json"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  root "github.com/salatfreak/mattermost-plugin-move"
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const confirmationExpiry = 10 * time.Minute
+
+// Move waiting for the user to confirm it
+type pendingMove struct {
+  TeamId string `json:"team_id"`
+  ChannelId string `json:"channel_id"`
+  RootId string `json:"root_id"`
+  UserId string `json:"user_id"`
+  Move *args.Move `json:"move"`
+  CommandChannelId string `json:"command_channel_id"`
+  CommandRootId string `json:"command_root_id"`
+  BasketPostIds []string `json:"basket_post_ids"`
+}
+
+func pendingKey(id string) string {
+  return "pending_" + id
+}
+
+func (p *Plug) needsConfirmation(plan *movePlan) (bool, error) {
+  config := p.getConfiguration()
+  if config.ConfirmPosts == 0 && config.ConfirmSize == 0 { return false, nil }
+  stats, err := p.getMoveStats(plan)
+  if err != nil { return false, err }
+  if config.ConfirmPosts > 0 && stats.posts > config.ConfirmPosts {
+    return true, nil
+  }
+  if config.ConfirmSize > 0 && stats.size > config.confirmSize() {
+    return true, nil
+  }
+  return false, nil
+}
+
+func (p *Plug) requestConfirmation(
+  pending *pendingMove, plan *movePlan,
+) (*model.CommandResponse, error) {
+  localizer := p.i18n.User(pending.UserId)
+
+  // Store pending move
+  id := model.NewId()
+  err := p.api.KV.SetWithExpiry(pendingKey(id), pending, confirmationExpiry)
+  if err != nil { return nil, err }
+
+  // Construct confirmation message
+  stats, err := p.getMoveStats(plan)
+  if err != nil { return nil, err }
+  text := localizer.Template(i18n.MsgConfirmQuestion, map[string]string{
+    "Posts": strconv.Itoa(stats.posts),
+    "Files": strconv.Itoa(stats.files),
+    "Size": formatSize(stats.size),
+  })
+  url := "/plugins/" + root.Manifest.Id + "/confirm"
+  action := func(name, style, decision string) *model.PostAction {
+    return &model.PostAction{
+      Id: decision, Type: model.PostActionTypeButton, Name: name, Style: style,
+      Integration: &model.PostActionIntegration{
+        URL: url,
+        Context: map[string]any{ "id": id, "decision": decision },
+      },
+    }
+  }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Attachments: []*model.SlackAttachment{{
+      Text: text,
+      Actions: []*model.PostAction{
+        action(localizer.Static(i18n.MsgConfirmMove), "primary", "move"),
+        action(localizer.Static(i18n.MsgConfirmCancel), "default", "cancel"),
+      },
+    }},
+  }, nil
+}
+
+func (p *Plug) handleConfirm(w http.ResponseWriter, r *http.Request) {
+  // Parse request
+  var request model.PostActionIntegrationRequest
+  err := json.NewDecoder(r.Body).Decode(&request)
+  if err != nil {
+    http.Error(w, "invalid request", http.StatusBadRequest)
+    return
+  }
+  userId := r.Header.Get("Mattermost-User-Id")
+  id, _ := request.Context["id"].(string)
+  decision, _ := request.Context["decision"].(string)
+  localizer := p.i18n.User(userId)
+
+  // Load and forget pending move
+  var pending *pendingMove
+  err = p.api.KV.Get(pendingKey(id), &pending)
+  if err == nil && pending == nil {
+    err = i18n.NewError(i18n.MsgErrorConfirmExpired)
+  }
+  if err == nil && pending.UserId != userId {
+    http.Error(w, "not allowed", http.StatusForbidden)
+    return
+  }
+  if err == nil { err = p.api.KV.Delete(pendingKey(id)) }
+
+  // Run move
+  text := localizer.Static(i18n.MsgConfirmCanceled)
+  if err == nil && decision == "move" {
+    var background bool
+    background, err = p.runPendingMove(pending)
+    text = localizer.Static(i18n.MsgConfirmMoved)
+    if background { text = localizer.Static(i18n.MsgJobStarted) }
+  }
+  if err != nil { text = p.responseFromError(err, localizer).Text }
+
+  // Replace confirmation message
+  w.Header().Set("Content-Type", "application/json")
+  _ = json.NewEncoder(w).Encode(&model.PostActionIntegrationResponse{
+    Update: &model.Post{ Message: text },
+  })
+}
+
+func (p *Plug) runPendingMove(pending *pendingMove) (bool, error) {
+  plan, err := p.planMove(
+    pending.TeamId, pending.ChannelId, pending.RootId, pending.UserId,
+    pending.Move,
+  )
+  if err != nil { return false, err }
+  plan.basketPostIds = pending.BasketPostIds
+  return p.executePlan(plan, pending.CommandChannelId, pending.CommandRootId)
+}
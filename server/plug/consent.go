@@ -0,0 +1,205 @@
+package plug
+
+import (
+  "time"
+  "strconv"
+  "net/http"
+  "encoding/json"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+  "github.com/mattermost/mattermost-plugin-api/cluster"
+
+  root "github.com/salatfreak/mattermost-plugin-move"
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+const consentExpiry = 24 * time.Hour
+
+// Move out of conversations waiting for their participants to agree
+type pendingConsent struct {
+  Move *pendingMove `json:"move"`
+  Awaiting []string `json:"awaiting"`
+  PostIds []string `json:"post_ids"`
+}
+
+func consentKey(id string) string {
+  return "consent_" + id
+}
+
+func (p *Plug) addConsentUsers(plan *movePlan, channelId string) error {
+  userIds, err := p.listChannelMembers(channelId)
+  if err != nil { return err }
+  for _, userId := range(userIds) {
+    if userId == plan.userId { continue }
+    if containsString(plan.consentUserIds, userId) { continue }
+    user, err := p.api.User.Get(userId)
+    if err != nil { return err }
+    if user.IsBot { continue }
+    plan.consentUserIds = append(plan.consentUserIds, userId)
+  }
+  return nil
+}
+
+func (p *Plug) requestConsent(
+  pending *pendingMove, plan *movePlan,
+) (*model.CommandResponse, error) {
+  consent := &pendingConsent{ Move: pending, Awaiting: plan.consentUserIds }
+  id := model.NewId()
+
+  // Ask in every affected conversation
+  user, err := p.api.User.Get(plan.userId)
+  if err != nil { return nil, err }
+  localizer := p.i18n.Server()
+  text := localizer.Template(i18n.MsgConsentQuestion, map[string]string{
+    "UserName": user.Username,
+    "Count": strconv.Itoa(countPosts(plan.posts)),
+    "ChannelName": plan.tgtChannel.DisplayName,
+  })
+  url := "/plugins/" + root.Manifest.Id + "/consent"
+  action := func(name, style, decision string) *model.PostAction {
+    return &model.PostAction{
+      Id: decision, Type: model.PostActionTypeButton, Name: name, Style: style,
+      Integration: &model.PostActionIntegration{
+        URL: url,
+        Context: map[string]any{ "id": id, "decision": decision },
+      },
+    }
+  }
+  for _, source := range(plan.srcPosts) {
+    channel, err := p.api.Channel.Get(source.ChannelId)
+    if err != nil { return nil, err }
+    if !channel.IsGroupOrDirect() { continue }
+    if p.hasConsentPost(consent, channel.Id) { continue }
+    post := &model.Post{ UserId: p.botId, ChannelId: channel.Id }
+    model.ParseSlackAttachment(post, []*model.SlackAttachment{{
+      Text: text,
+      Actions: []*model.PostAction{
+        action(localizer.Static(i18n.MsgConsentAllow), "primary", "allow"),
+        action(localizer.Static(i18n.MsgConsentDecline), "danger", "decline"),
+      },
+    }})
+    err = p.api.Post.CreatePost(post)
+    if err != nil { return nil, err }
+    consent.PostIds = append(consent.PostIds, post.Id)
+  }
+
+  // Store pending move
+  err = p.api.KV.SetWithExpiry(consentKey(id), consent, consentExpiry)
+  if err != nil { return nil, err }
+  return &model.CommandResponse{
+    ResponseType: model.CommandResponseTypeEphemeral,
+    Text: p.i18n.User(plan.userId).Static(i18n.MsgConsentRequested),
+  }, nil
+}
+
+func (p *Plug) hasConsentPost(consent *pendingConsent, channelId string) bool {
+  for _, postId := range(consent.PostIds) {
+    post, err := p.api.Post.GetPost(postId)
+    if err == nil && post.ChannelId == channelId { return true }
+  }
+  return false
+}
+
+func (p *Plug) handleConsent(w http.ResponseWriter, r *http.Request) {
+  // Parse request
+  var request model.PostActionIntegrationRequest
+  err := json.NewDecoder(r.Body).Decode(&request)
+  if err != nil {
+    http.Error(w, "invalid request", http.StatusBadRequest)
+    return
+  }
+  userId := r.Header.Get("Mattermost-User-Id")
+  id, _ := request.Context["id"].(string)
+  decision, _ := request.Context["decision"].(string)
+  localizer := p.i18n.User(userId)
+  respond := func(text string) {
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(&model.PostActionIntegrationResponse{
+      EphemeralText: text,
+    })
+  }
+
+  // Serialize decisions of several participants
+  mutex, err := cluster.NewMutex(p.API, consentKey(id))
+  if err != nil {
+    respond(p.responseFromError(err, localizer).Text)
+    return
+  }
+  mutex.Lock()
+  defer mutex.Unlock()
+
+  // Load pending move
+  var consent *pendingConsent
+  err = p.api.KV.Get(consentKey(id), &consent)
+  if err == nil && consent == nil {
+    err = i18n.NewError(i18n.MsgErrorConfirmExpired)
+  }
+  if err != nil {
+    respond(p.responseFromError(err, localizer).Text)
+    return
+  }
+  if !containsString(consent.Awaiting, userId) {
+    respond(localizer.Static(i18n.MsgConsentNotAsked))
+    return
+  }
+  user, err := p.api.User.Get(userId)
+  if err != nil {
+    respond(p.responseFromError(err, localizer).Text)
+    return
+  }
+
+  // Cancel move if declined
+  server := p.i18n.Server()
+  if decision != "allow" {
+    err = p.api.KV.Delete(consentKey(id))
+    if err != nil {
+      p.api.Log.Warn("Failed to delete consent", "error", err.Error())
+    }
+    p.closeConsent(consent, server.Template(
+      i18n.MsgConsentDeclined, map[string]string{ "UserName": user.Username },
+    ))
+    respond("")
+    return
+  }
+
+  // Record consent and wait for the others
+  awaiting := make([]string, 0, len(consent.Awaiting))
+  for _, other := range(consent.Awaiting) {
+    if other != userId { awaiting = append(awaiting, other) }
+  }
+  consent.Awaiting = awaiting
+  if len(awaiting) > 0 {
+    err = p.api.KV.SetWithExpiry(consentKey(id), consent, consentExpiry)
+    if err != nil {
+      respond(p.responseFromError(err, localizer).Text)
+      return
+    }
+    respond(localizer.Static(i18n.MsgConsentRecorded))
+    return
+  }
+
+  // Run move once everybody agreed
+  err = p.api.KV.Delete(consentKey(id))
+  if err == nil { _, err = p.runPendingMove(consent.Move) }
+  text := server.Static(i18n.MsgConsentMoved)
+  if err != nil {
+    text = p.responseFromError(err, p.i18n.User(consent.Move.UserId)).Text
+  }
+  p.closeConsent(consent, text)
+  respond("")
+}
+
+// Replace the questions, removing their buttons
+func (p *Plug) closeConsent(consent *pendingConsent, text string) {
+  for _, postId := range(consent.PostIds) {
+    post, err := p.api.Post.GetPost(postId)
+    if err == nil {
+      post.DelProp("attachments")
+      post.Message = text
+      err = p.api.Post.UpdatePost(post)
+    }
+    if err != nil {
+      p.api.Log.Warn("Failed to update consent post", "error", err.Error())
+    }
+  }
+}
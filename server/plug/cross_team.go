@@ -0,0 +1,95 @@
+package plug
+
+import (
+  "strings"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+// Handling of authors who aren't members of the target team
+const (
+  crossTeamAuthorsRequire = "require"
+  crossTeamAuthorsAdd = "add"
+  crossTeamAuthorsWarn = "warn"
+)
+
+func (p *Plug) assertTeamMember(userId, teamId string) error {
+  if teamId == "" { return nil }
+  member, err := p.api.Team.GetMember(teamId, userId)
+  if err == nil && member.DeleteAt == 0 { return nil }
+  team, err := p.api.Team.Get(teamId)
+  if err != nil { return err }
+  return i18n.NewError(i18n.MsgErrorTeamMember, "TeamName", team.DisplayName)
+}
+
+// Check authors of messages from other teams against the target team
+func (p *Plug) checkCrossTeamAuthors(plan *movePlan) error {
+  teamId := plan.tgtChannel.TeamId
+  if teamId == "" { return nil }
+
+  // Find authors missing in the target team
+  known := make(map[string]bool)
+  for i, source := range(plan.srcPosts) {
+    channel, err := p.api.Channel.Get(source.ChannelId)
+    if err != nil { return err }
+    if channel.TeamId == teamId { continue }
+    for _, post := range(plan.posts[i]) {
+      if known[post.UserId] { continue }
+      known[post.UserId] = true
+      member, err := p.api.Team.GetMember(teamId, post.UserId)
+      if err == nil && member.DeleteAt == 0 { continue }
+      user, err := p.api.User.Get(post.UserId)
+      if err != nil { return err }
+      if user.IsBot { continue }
+      plan.foreignAuthors = append(plan.foreignAuthors, user)
+    }
+  }
+
+  // Refuse move unless configured otherwise
+  policy := p.getConfiguration().CrossTeamAuthors
+  if len(plan.foreignAuthors) == 0 { return nil }
+  if policy == crossTeamAuthorsAdd || policy == crossTeamAuthorsWarn {
+    return nil
+  }
+  team, err := p.api.Team.Get(teamId)
+  if err != nil { return err }
+  return i18n.NewError(
+    i18n.MsgErrorAuthorsNotInTeam,
+    "Users", formatUsers(plan.foreignAuthors), "TeamName", team.DisplayName,
+  )
+}
+
+func (p *Plug) addForeignAuthors(plan *movePlan) error {
+  if p.getConfiguration().CrossTeamAuthors != crossTeamAuthorsAdd {
+    return nil
+  }
+  for _, user := range(plan.foreignAuthors) {
+    _, err := p.api.Team.CreateMember(plan.tgtChannel.TeamId, user.Id)
+    if err != nil { return err }
+    p.api.Log.Debug("Added author to target team", "user", user.Id)
+  }
+  return nil
+}
+
+// Warning about authors who can't see their moved messages
+func (p *Plug) crossTeamWarning(
+  plan *movePlan, localizer *i18n.Localizer,
+) (string, error) {
+  policy := p.getConfiguration().CrossTeamAuthors
+  if len(plan.foreignAuthors) == 0 || policy != crossTeamAuthorsWarn {
+    return "", nil
+  }
+  team, err := p.api.Team.Get(plan.tgtChannel.TeamId)
+  if err != nil { return "", err }
+  return localizer.Template(i18n.MsgWarningAuthorsNotInTeam, map[string]string{
+    "Users": formatUsers(plan.foreignAuthors), "TeamName": team.DisplayName,
+  }), nil
+}
+
+func formatUsers(users []*model.User) string {
+  names := make([]string, 0, len(users))
+  for _, user := range(users) { names = append(names, "@" + user.Username) }
+  return strings.Join(names, ", ")
+}
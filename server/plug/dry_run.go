@@ -0,0 +1,95 @@
+package plug
+
+import (
+  "fmt"
+  "strings"
+  "strconv"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+// Numbers describing the extent of a move
+type moveStats struct {
+  posts int
+  replies int
+  files int
+  size int64
+  reactions int
+  authorIds []string
+}
+
+func (p *Plug) getMoveStats(plan *movePlan) (*moveStats, error) {
+  stats := &moveStats{ authorIds: make([]string, 0) }
+  knownAuthors := make(map[string]bool)
+  for _, posts := range(plan.posts) {
+    for _, post := range(posts) {
+      stats.posts++
+      if post.RootId != "" { stats.replies++ }
+      for _, fileId := range(post.FileIds) {
+        info, err := p.api.File.GetInfo(fileId)
+        if err != nil { return nil, err }
+        stats.files++
+        stats.size += info.Size
+      }
+      reactions, err := p.api.Post.GetReactions(post.Id)
+      if err != nil { return nil, err }
+      stats.reactions += len(reactions)
+      if !knownAuthors[post.UserId] {
+        knownAuthors[post.UserId] = true
+        stats.authorIds = append(stats.authorIds, post.UserId)
+      }
+    }
+  }
+  return stats, nil
+}
+
+func (p *Plug) summarizeMovePlan(
+  plan *movePlan, localizer *i18n.Localizer,
+) (string, error) {
+  stats, err := p.getMoveStats(plan)
+  if err != nil { return "", err }
+
+  // List authors
+  authors := make([]string, 0, len(stats.authorIds))
+  for _, authorId := range(stats.authorIds) {
+    user, err := p.api.User.Get(authorId)
+    if err != nil { return "", err }
+    authors = append(authors, "@" + user.Username)
+  }
+
+  // Describe target
+  var target string
+  if plan.tgtPost != nil {
+    link, err := p.getPermalink(plan.tgtPost)
+    if err != nil { return "", err }
+    target = localizer.Template(i18n.MsgDryRunTargetThread, map[string]string{
+      "Link": link, "ChannelName": plan.tgtChannel.Name,
+    })
+  } else {
+    target = "~" + plan.tgtChannel.Name
+  }
+
+  // Construct summary
+  msg := i18n.MsgDryRunMove
+  if plan.move.Copy { msg = i18n.MsgDryRunCopy }
+  return localizer.Template(msg, map[string]string{
+    "Posts": strconv.Itoa(stats.posts),
+    "Replies": strconv.Itoa(stats.replies),
+    "Files": strconv.Itoa(stats.files),
+    "Size": formatSize(stats.size),
+    "Reactions": strconv.Itoa(stats.reactions),
+    "Authors": strings.Join(authors, ", "),
+    "Target": target,
+  }), nil
+}
+
+func formatSize(size int64) string {
+  const unit = 1024
+  if size < unit { return fmt.Sprintf("%d B", size) }
+  div, exp := int64(unit), 0
+  for n := size / unit; n >= unit; n /= unit {
+    div *= unit
+    exp++
+  }
+  return fmt.Sprintf("%.1f %ciB", float64(size) / float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,84 @@
+package plug
+
+import (
+  "sort"
+  "strings"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const (
+  filterPageSize = 200
+  filterScanLimit = 5000
+)
+
+// Replace filter by the messages of the channel matching it
+func (p *Plug) resolveFilter(teamId, channelId string, move *args.Move) error {
+  filter := move.Filter
+  if filter == nil { return nil }
+
+  // Resolve channel and user
+  if filter.ChannelName != "" {
+    var err error
+    channelId, _, err = p.resolveTarget(teamId, &args.Target{
+      TeamName: filter.TeamName, ChannelName: filter.ChannelName,
+    })
+    if err != nil { return err }
+  }
+  var userId string
+  if filter.UserName != "" {
+    user, err := p.api.User.GetByUsername(filter.UserName)
+    if err != nil {
+      return i18n.NewError(
+        i18n.MsgErrorUserNotExist, "UserName", filter.UserName,
+      )
+    }
+    userId = user.Id
+  }
+  var since int64
+  if filter.Since > 0 {
+    since = model.GetMillis() - filter.Since.Milliseconds()
+  }
+  contains := strings.ToLower(filter.Contains)
+
+  // Scan channel from the newest message backwards
+  matches := make([]*model.Post, 0)
+  scanned := 0
+  for page := 0; scanned < filterScanLimit; page++ {
+    list, err := p.api.Post.GetPostsForChannel(channelId, page, filterPageSize)
+    if err != nil { return err }
+    posts := list.ToSlice()
+    for _, post := range(posts) {
+      if post.CreateAt < since { continue }
+      if post.DeleteAt != 0 || post.IsSystemMessage() { continue }
+      if userId != "" && post.UserId != userId { continue }
+      if !strings.Contains(strings.ToLower(post.Message), contains) { continue }
+      matches = append(matches, post)
+    }
+    scanned += len(posts)
+    if len(posts) < filterPageSize { break }
+    if posts[len(posts) - 1].CreateAt < since { break }
+  }
+
+  // Select matches oldest first, leaving replies to their moved roots
+  sort.Slice(matches, func(i, j int) bool {
+    return matches[i].CreateAt < matches[j].CreateAt
+  })
+  selected := make([]string, 0, len(matches))
+  for _, post := range(matches) {
+    if post.RootId != "" && containsString(selected, post.RootId) { continue }
+    selected = append(selected, post.Id)
+  }
+  if len(selected) == 0 { return i18n.NewError(i18n.MsgErrorNoMatches) }
+  p.api.Log.Debug("Resolved filter", "filter", filter, "posts", selected)
+  for _, postId := range(selected) {
+    if !containsString(move.Sources, postId) {
+      move.Sources = append(move.Sources, postId)
+    }
+  }
+  move.Filter = nil
+  return nil
+}
@@ -0,0 +1,31 @@
+package plug
+
+import (
+  "strings"
+  "net/http"
+
+  "github.com/mattermost/mattermost-server/v6/plugin"
+)
+
+// Handle HTTP requests to the plugin
+func (p *Plug) ServeHTTP(
+  c *plugin.Context, w http.ResponseWriter, r *http.Request,
+) {
+  // Require authenticated user
+  if r.Header.Get("Mattermost-User-Id") == "" {
+    http.Error(w, "not authorized", http.StatusUnauthorized)
+    return
+  }
+
+  // Route request
+  switch {
+    case r.Method == http.MethodPost && r.URL.Path == "/confirm":
+      p.handleConfirm(w, r)
+    case r.Method == http.MethodPost && r.URL.Path == "/consent":
+      p.handleConsent(w, r)
+    case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/goto/"):
+      p.handleGoto(w, r)
+    default:
+      http.NotFound(w, r)
+  }
+}
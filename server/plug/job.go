@@ -0,0 +1,221 @@
+package plug
+
+import (
+  "strconv"
+  "context"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+  "github.com/mattermost/mattermost-plugin-api/cluster"
+  pluginapi "github.com/mattermost/mattermost-plugin-api"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const (
+  jobKeyPrefix = "job_"
+  jobProgressInterval = 10
+)
+
+// Move running in the background, persisted to survive restarts
+type moveJob struct {
+  Id string `json:"id"`
+  UserId string `json:"user_id"`
+  Move *args.Move `json:"move"`
+  TargetChannel string `json:"target_channel"`
+  TargetPost string `json:"target_post"`
+  SrcPosts []*model.Post `json:"src_posts"`
+  Posts [][]*model.Post `json:"posts"`
+  OperationKey string `json:"operation_key"`
+  ForeignAuthorIds []string `json:"foreign_author_ids"`
+  Forced bool `json:"forced"`
+  BasketPostIds []string `json:"basket_post_ids"`
+  ProgressPost *model.Post `json:"progress_post"`
+}
+
+func jobKey(id string) string {
+  return jobKeyPrefix + id
+}
+
+// Run plan directly or as a background job, reporting which one. Basket
+// messages are taken out of the basket once they are moved.
+func (p *Plug) executePlan(
+  plan *movePlan, channelId, rootId string,
+) (bool, error) {
+  if p.needsBackground(plan) {
+    return true, p.startJob(plan, channelId, rootId)
+  }
+  err := p.runMovePlan(plan)
+  if err != nil { return false, err }
+  p.removeFromBasket(plan.userId, plan.basketPostIds)
+  return false, nil
+}
+
+func (p *Plug) needsBackground(plan *movePlan) bool {
+  limit := p.getConfiguration().BackgroundPosts
+  return limit > 0 && countPosts(plan.posts) > limit
+}
+
+func (p *Plug) startJob(plan *movePlan, channelId, rootId string) error {
+  job := &moveJob{
+    Id: plan.operationId,
+    UserId: plan.userId,
+    Move: plan.move,
+    TargetChannel: plan.tgtChannel.Id,
+    SrcPosts: plan.srcPosts,
+    Posts: plan.posts,
+    OperationKey: plan.opKey,
+    Forced: plan.forced,
+    BasketPostIds: plan.basketPostIds,
+  }
+  if plan.tgtPost != nil { job.TargetPost = plan.tgtPost.Id }
+  for _, user := range(plan.foreignAuthors) {
+    job.ForeignAuthorIds = append(job.ForeignAuthorIds, user.Id)
+  }
+
+  // Persist job, refusing to start the same operation twice
+  job.ProgressPost = &model.Post{
+    Id: model.NewId(),
+    UserId: p.botId,
+    ChannelId: channelId,
+    RootId: rootId,
+    Message: p.i18n.User(plan.userId).Static(i18n.MsgJobStarted),
+  }
+  ok, err := p.api.KV.Set(jobKey(job.Id), job, pluginapi.SetAtomic(nil))
+  if err != nil { return err }
+  if !ok { return i18n.NewError(i18n.MsgErrorAlreadyMoving) }
+  err = p.addToIndex(jobIndexKey, jobKeyPrefix, jobKey(job.Id))
+  if err != nil { return err }
+
+  // Announce and run job
+  p.api.Post.SendEphemeralPost(plan.userId, job.ProgressPost)
+  go p.runJob(job)
+  return nil
+}
+
+// Resume jobs interrupted by a restart
+func (p *Plug) resumeJobs() {
+  // Only let one node at a time pick up jobs
+  mutex, err := cluster.NewMutex(p.API, "resume_jobs")
+  if err != nil {
+    p.api.Log.Error("Failed to lock jobs", "error", err.Error())
+    return
+  }
+  mutex.Lock()
+  defer mutex.Unlock()
+
+  keys, err := p.getIndex(jobIndexKey, jobKeyPrefix)
+  if err != nil {
+    p.api.Log.Error("Failed to list jobs", "error", err.Error())
+    return
+  }
+  for _, key := range(keys) {
+    var job *moveJob
+    err := p.api.KV.Get(key, &job)
+    if err != nil {
+      p.api.Log.Error("Failed to load job", "key", key, "error", err.Error())
+      continue
+    }
+    if job == nil { continue }
+    p.api.Log.Info("Resuming move job", "job", job.Id)
+    go p.runJob(job)
+  }
+}
+
+func (p *Plug) runJob(job *moveJob) {
+  // Leave jobs still running elsewhere alone
+  unlock, ok := p.lockJob(job)
+  if !ok { return }
+  defer unlock()
+
+  // Run move
+  plan, err := p.planFromJob(job)
+  if err == nil { err = p.runMovePlan(plan) }
+  if err == nil { p.removeFromBasket(job.UserId, job.BasketPostIds) }
+
+  // Report result
+  localizer := p.i18n.User(job.UserId)
+  text := localizer.Template(i18n.MsgJobDone, map[string]string{
+    "Count": strconv.Itoa(countPosts(job.Posts)),
+  })
+  if err != nil { text = p.responseFromError(err, localizer).Text }
+  p.updateJobProgress(job, text)
+
+  // Forget job
+  err = p.api.KV.Delete(jobKey(job.Id))
+  if err == nil {
+    err = p.removeFromIndex(jobIndexKey, jobKeyPrefix, jobKey(job.Id))
+  }
+  if err != nil {
+    p.api.Log.Error(
+      "Failed to delete job", "job", job.Id, "error", err.Error(),
+    )
+  }
+}
+
+// Take the job across the cluster, reporting false if it is taken or gone
+func (p *Plug) lockJob(job *moveJob) (func(), bool) {
+  mutex, err := cluster.NewMutex(p.API, jobKey(job.Id))
+  if err != nil {
+    p.api.Log.Error("Failed to lock job", "job", job.Id, "error", err.Error())
+    return nil, false
+  }
+  ctx, cancel := context.WithTimeout(context.Background(), lockWaitJob)
+  defer cancel()
+  err = mutex.LockWithContext(ctx)
+  if err != nil {
+    p.api.Log.Debug("Job is running elsewhere", "job", job.Id)
+    return nil, false
+  }
+
+  // The job may have finished while waiting for the lock
+  var stored *moveJob
+  err = p.api.KV.Get(jobKey(job.Id), &stored)
+  if err != nil || stored == nil {
+    mutex.Unlock()
+    return nil, false
+  }
+  return mutex.Unlock, true
+}
+
+func (p *Plug) planFromJob(job *moveJob) (*movePlan, error) {
+  plan := &movePlan{
+    operationId: job.Id,
+    opKey: job.OperationKey,
+    userId: job.UserId,
+    move: job.Move,
+    srcPosts: job.SrcPosts,
+    posts: job.Posts,
+    forced: job.Forced,
+    job: job,
+  }
+  var err error
+  plan.tgtChannel, err = p.api.Channel.Get(job.TargetChannel)
+  if err != nil { return nil, err }
+  if job.TargetPost != "" {
+    plan.tgtPost, err = p.api.Post.GetPost(job.TargetPost)
+    if err != nil { return nil, err }
+  }
+  for _, userId := range(job.ForeignAuthorIds) {
+    user, err := p.api.User.Get(userId)
+    if err != nil { return nil, err }
+    plan.foreignAuthors = append(plan.foreignAuthors, user)
+  }
+  return plan, nil
+}
+
+func (p *Plug) reportJobProgress(job *moveJob, done int) {
+  if done % jobProgressInterval != 0 { return }
+  localizer := p.i18n.User(job.UserId)
+  p.updateJobProgress(job, localizer.Template(
+    i18n.MsgJobProgress, map[string]string{
+      "Done": strconv.Itoa(done),
+      "Total": strconv.Itoa(countPosts(job.Posts)),
+    },
+  ))
+}
+
+func (p *Plug) updateJobProgress(job *moveJob, message string) {
+  job.ProgressPost.Message = message
+  p.api.Post.UpdateEphemeralPost(job.UserId, job.ProgressPost)
+}
@@ -0,0 +1,70 @@
+package plug
+
+import (
+  "sort"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const lastPageSize = 200
+
+// Replace selection of the last messages by their IDs
+func (p *Plug) resolveLast(teamId string, move *args.Move) error {
+  last := move.Last
+  if last == nil { return nil }
+
+  // Collect the most recent messages of the thread or channel
+  var posts []*model.Post
+  if last.Source.PostId != "" {
+    post, err := p.api.Post.GetPost(last.Source.PostId)
+    if err != nil {
+      return i18n.NewError(
+        i18n.MsgErrorNotExist, "PostId", last.Source.PostId,
+      )
+    }
+    rootId := post.RootId
+    if rootId == "" { rootId = post.Id }
+    thread, err := p.getThreadPosts(rootId)
+    if err != nil { return err }
+    for i := len(thread) - 1; i > 0 && len(posts) < last.Count; i-- {
+      if isMovable(thread[i]) { posts = append(posts, thread[i]) }
+    }
+  } else {
+    channelId, _, err := p.resolveTarget(teamId, last.Source)
+    if err != nil { return err }
+    for page := 0; len(posts) < last.Count; page++ {
+      list, err := p.api.Post.GetPostsForChannel(
+        channelId, page, lastPageSize,
+      )
+      if err != nil { return err }
+      for _, post := range(list.ToSlice()) {
+        if len(posts) == last.Count { break }
+        if isMovable(post) { posts = append(posts, post) }
+      }
+      if len(list.Order) < lastPageSize { break }
+    }
+  }
+  if len(posts) == 0 { return i18n.NewError(i18n.MsgErrorNoMatches) }
+
+  // Select oldest first, leaving replies to their moved roots
+  sort.Slice(posts, func(i, j int) bool {
+    return posts[i].CreateAt < posts[j].CreateAt
+  })
+  for _, post := range(posts) {
+    if post.RootId != "" && containsString(move.Sources, post.RootId) {
+      continue
+    }
+    move.Sources = append(move.Sources, post.Id)
+  }
+  p.api.Log.Debug("Resolved last messages", "posts", move.Sources)
+  move.Last = nil
+  return nil
+}
+
+// Whether a post is a regular message rather than a system message
+func isMovable(post *model.Post) bool {
+  return post.DeleteAt == 0 && !post.IsSystemMessage()
+}
@@ -0,0 +1,28 @@
+package plug
+
+import (
+  "github.com/mattermost/mattermost-server/v6/model"
+)
+
+func (p *Plug) getPermalink(post *model.Post) (string, error) {
+  siteURL := *p.api.Configuration.GetConfig().ServiceSettings.SiteURL
+
+  // Use redirect route for channels without team
+  channel, err := p.api.Channel.Get(post.ChannelId)
+  if err != nil { return "", err }
+  if channel.TeamId == "" {
+    return siteURL + "/_redirect/pl/" + post.Id, nil
+  }
+
+  // Construct team specific permalink
+  team, err := p.api.Team.Get(channel.TeamId)
+  if err != nil { return "", err }
+  return siteURL + "/" + team.Name + "/pl/" + post.Id, nil
+}
+
+// Whether the server refuses editing the message of the post
+func (p *Plug) editTimeLimitExceeded(post *model.Post) bool {
+  limit := p.api.Configuration.GetConfig().ServiceSettings.PostEditTimeLimit
+  if limit == nil || *limit == -1 { return false }
+  return model.GetMillis() > post.CreateAt + int64(*limit) * 1000
+}
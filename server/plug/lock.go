@@ -0,0 +1,66 @@
+package plug
+
+import (
+  "sort"
+  "time"
+  "context"
+
+  "github.com/mattermost/mattermost-plugin-api/cluster"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+const (
+  lockWait = time.Second
+  // Locks held before a restart expire after 15 seconds
+  lockWaitJob = 20 * time.Second
+)
+
+// Lock the threads touched by a move across the cluster
+func (p *Plug) lockMove(plan *movePlan) (func(), error) {
+  wait := lockWait
+  if plan.job != nil { wait = lockWaitJob }
+  ctx, cancel := context.WithTimeout(context.Background(), wait)
+  defer cancel()
+
+  // Acquire locks in a stable order
+  mutexes := make([]*cluster.Mutex, 0)
+  unlock := func() {
+    for i := len(mutexes) - 1; i >= 0; i-- { mutexes[i].Unlock() }
+  }
+  for _, key := range(moveLockKeys(plan)) {
+    mutex, err := cluster.NewMutex(p.API, "move_" + key)
+    if err != nil {
+      unlock()
+      return nil, err
+    }
+    err = mutex.LockWithContext(ctx)
+    if err != nil {
+      unlock()
+      p.api.Log.Debug("Failed to acquire move lock", "key", key)
+      return nil, i18n.NewError(i18n.MsgErrorAlreadyMoving)
+    }
+    mutexes = append(mutexes, mutex)
+  }
+  return unlock, nil
+}
+
+// Root IDs of the source threads and the target thread
+func moveLockKeys(plan *movePlan) []string {
+  keys := make([]string, 0, len(plan.srcPosts) + 1)
+  add := func(rootId string) {
+    if !containsString(keys, rootId) { keys = append(keys, rootId) }
+  }
+  for _, post := range(plan.srcPosts) {
+    if post.RootId != "" { add(post.RootId) } else { add(post.Id) }
+  }
+  if plan.tgtPost != nil {
+    if plan.tgtPost.RootId != "" {
+      add(plan.tgtPost.RootId)
+    } else {
+      add(plan.tgtPost.Id)
+    }
+  }
+  sort.Strings(keys)
+  return keys
+}
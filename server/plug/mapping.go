@@ -0,0 +1,75 @@
+package plug
+
+import (
+  "net/http"
+  "strings"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Limit for following chains of moves, guarding against cycles
+const maxMoveChain = 100
+
+func movedKey(postId string) string {
+  return "moved_" + postId
+}
+
+// Remember new IDs of moved posts
+func (p *Plug) savePostMappings(
+  posts [][]*model.Post, txs []*transaction,
+) error {
+  for i, group := range(posts) {
+    for j, post := range(group) {
+      _, err := p.api.KV.Set(movedKey(post.Id), txs[i].posts[j].Id)
+      if err != nil { return err }
+    }
+  }
+  return nil
+}
+
+// Follow moves to the current ID of the post
+func (p *Plug) resolvePostId(postId string) (string, bool, error) {
+  moved := false
+  for i := 0; i < maxMoveChain; i++ {
+    var newId string
+    err := p.api.KV.Get(movedKey(postId), &newId)
+    if err != nil { return "", false, err }
+    if newId == "" { break }
+    postId, moved = newId, true
+  }
+  return postId, moved, nil
+}
+
+func (p *Plug) handleGoto(w http.ResponseWriter, r *http.Request) {
+  // Resolve current post
+  postId := strings.TrimPrefix(r.URL.Path, "/goto/")
+  postId, _, err := p.resolvePostId(postId)
+  if err != nil {
+    p.api.Log.Error("Failed to resolve post", "error", err.Error())
+    http.Error(w, "server error", http.StatusInternalServerError)
+    return
+  }
+  post, err := p.api.Post.GetPost(postId)
+  if err != nil {
+    http.NotFound(w, r)
+    return
+  }
+
+  // Don't reveal where messages went to users who can't read them there
+  userId := r.Header.Get("Mattermost-User-Id")
+  if userId == "" || !p.api.User.HasPermissionToChannel(
+    userId, post.ChannelId, model.PermissionReadChannel,
+  ) {
+    http.NotFound(w, r)
+    return
+  }
+
+  // Redirect to permalink
+  link, err := p.getPermalink(post)
+  if err != nil {
+    p.api.Log.Error("Failed to construct permalink", "error", err.Error())
+    http.Error(w, "server error", http.StatusInternalServerError)
+    return
+  }
+  http.Redirect(w, r, link, http.StatusFound)
+}
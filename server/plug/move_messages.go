@@ -1,58 +1,231 @@
 package plug
 
 import (
+  "sync"
   "time"
+  "strings"
+  "strconv"
+  "context"
   "encoding/json"
 
   "github.com/mattermost/mattermost-server/v6/model"
 
   "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
 )
 
-func (p *Plug) runMoveMessages(
-  teamId, channelId, targetPostId, userId string, sourcePostIds []string,
-) error {
+// Resolved and checked move, ready to be executed
+type movePlan struct {
+  operationId string
+  opKey string
+  op *operation
+  userId string
+  move *args.Move
+  tgtChannel *model.Channel
+  tgtPost *model.Post
+  srcPosts []*model.Post
+  posts [][]*model.Post
+  foreignAuthors []*model.User
+  forced bool
+  consentUserIds []string
+  basketPostIds []string
+  job *moveJob
+}
+
+func (p *Plug) planMove(
+  teamId, channelId, targetPostId, userId string, move *args.Move,
+) (*movePlan, error) {
   p.api.Log.Debug(
-    "Running move command",
+    "Planning move",
     "team", teamId, "channel", channelId, "targetPost", targetPostId,
-    "user", userId, "sourcePosts", sourcePostIds,
+    "user", userId, "sourcePosts", move.Sources, "copy", move.Copy,
   )
+  plan := &movePlan{
+    opKey: operationKey(userId, channelId, targetPostId, move),
+    userId: userId,
+    move: move,
+  }
+
+  // Detect repetitions of finished moves
+  var err error
+  plan.op, err = p.loadOperation(plan.opKey, model.NewId())
+  if err != nil { return nil, err }
+  err = assertNotDone(plan.op, move.Copy)
+  if err != nil { return nil, err }
+  plan.operationId = plan.op.Id
 
   // Get target channel and post
-  tgtChannel, err := p.api.Channel.Get(channelId)
-  if err != nil { return err }
-  var tgtPost *model.Post
+  plan.tgtChannel, err = p.api.Channel.Get(channelId)
+  if err != nil { return nil, err }
   if targetPostId != "" {
-    tgtPost, err = p.api.Post.GetPost(targetPostId)
-    if err != nil { return err }
+    plan.tgtPost, err = p.api.Post.GetPost(targetPostId)
+    if err != nil { return nil, err }
   }
 
-  // Get source posts
-  srcPosts, err := p.getPostsFromIds(sourcePostIds)
-  if err != nil { return err }
+  // Get source posts and their threads, restoring them for retries of moves
+  // that failed after deleting some of them
+  if plan.op.partlyDeleted() {
+    plan.srcPosts, plan.posts = plan.op.SrcPosts, plan.op.Posts
+  } else {
+    plan.srcPosts, err = p.getPostsFromIds(move.Sources)
+    if err != nil { return nil, err }
+    plan.posts = make([][]*model.Post, 0, len(plan.srcPosts))
+    for _, post := range(plan.srcPosts) {
+      posts, err := p.getMovedPosts(post)
+      if err != nil { return nil, err }
+      plan.posts = append(plan.posts, posts)
+    }
+  }
 
   // Check permissions
-  for _, post := range(srcPosts) {
+  tgtPost := plan.tgtPost
+  for i, post := range(plan.srcPosts) {
     if tgtPost != nil && post.Id == tgtPost.Id {
-      return i18n.NewError(i18n.MsgErrorAttachItself)
+      return nil, i18n.NewError(i18n.MsgErrorAttachItself)
     }
     if tgtPost != nil && post.CreateAt <= tgtPost.CreateAt {
-      return i18n.NewError(i18n.MsgErrorNewerMessage)
+      return nil, i18n.NewError(i18n.MsgErrorNewerMessage)
     }
-    err := p.assertSourcePermissions(userId, post, tgtChannel)
-    if err != nil { return err }
+    err := p.assertSourcePermissions(
+      userId, post, plan.posts[i], plan.tgtChannel, move.Copy,
+    )
+    if err != nil { return nil, err }
+  }
+  checked := make([]string, 0, len(plan.srcPosts))
+  for _, post := range(plan.srcPosts) {
+    if containsString(checked, post.ChannelId) { continue }
+    checked = append(checked, post.ChannelId)
+    srcChannel, err := p.api.Channel.Get(post.ChannelId)
+    if err != nil { return nil, err }
+    err = p.assertPrivateAudience(plan, srcChannel)
+    if err != nil { return nil, err }
   }
-  err = p.assertTargetPermissions(userId, tgtChannel)
+  err = p.assertTargetPermissions(userId, plan.tgtChannel)
+  if err != nil { return nil, err }
+  err = p.assertRolePermissions(userId, plan.tgtChannel)
+  if err != nil { return nil, err }
+  err = p.assertPostLimit(plan.posts)
+  if err != nil { return nil, err }
+  err = p.checkCrossTeamAuthors(plan)
+  if err != nil { return nil, err }
+
+  return plan, nil
+}
+
+func (p *Plug) runMovePlan(plan *movePlan) error {
+  p.api.Log.Debug("Running move plan", "plan", plan)
+
+  // Keep others from moving the same threads meanwhile
+  unlock, err := p.lockMove(plan)
   if err != nil { return err }
+  defer unlock()
 
-  // Move messages
-  for _, post := range(srcPosts) {
-    err = p.movePost(userId, post, tgtChannel, tgtPost)
-    if err != nil { return err }
+  // Pick up progress of earlier attempts
+  plan.op, err = p.loadOperation(plan.opKey, plan.operationId)
+  if err != nil { return err }
+  err = assertNotDone(plan.op, plan.move.Copy)
+  if err != nil { return err }
+  plan.operationId = plan.op.Id
+  if plan.op.partlyDeleted() {
+    plan.srcPosts, plan.posts = plan.op.SrcPosts, plan.op.Posts
+  }
+  plan.op.SrcPosts, plan.op.Posts = plan.srcPosts, plan.posts
+  if plan.job == nil && len(plan.op.Deleted) == 0 {
+    for _, post := range(plan.srcPosts) {
+      if !p.postExists(post.Id) {
+        return i18n.NewError(i18n.MsgErrorNotExist, "PostId", post.Id)
+      }
+    }
   }
+
+  err = p.addForeignAuthors(plan)
+  if err != nil { return err }
+
+  // Copy messages
+  txs := make([]*transaction, 0, len(plan.srcPosts))
+  for _, posts := range(plan.posts) {
+    tx := newTransaction()
+    p.trackOperation(tx, plan)
+    txs = append(txs, tx)
+    err := p.copyPosts(
+      tx, plan.operationId, plan.userId, posts, plan.tgtChannel, plan.tgtPost,
+      plan.move.Copy,
+    )
+    if err != nil { return p.abortOperation(plan, txs, false, err) }
+  }
+  if plan.move.Copy {
+    p.finishOperation(plan)
+    p.recordAuditEntry(plan, txs)
+    return nil
+  }
+
+  // Delete original messages
+  for i, post := range(plan.srcPosts) {
+    if containsString(plan.op.Deleted, post.Id) { continue }
+    err := p.api.Post.DeletePost(post.Id)
+    if err != nil && !p.postExists(post.Id) { err = nil }
+    if err != nil {
+      return p.abortOperation(plan, txs[i:], len(plan.op.Deleted) > 0, err)
+    }
+    p.recordDeletion(plan, post.Id)
+    p.api.Log.Debug("Deleted original post", "post", post.Id)
+  }
+
+  p.finishOperation(plan)
+
+  // Leave tombstones
+  tombstoneIds := p.leaveTombstones(
+    plan.userId, plan.srcPosts, txs, plan.tgtChannel, plan.move,
+  )
+
+  // Remember new IDs of moved messages
+  err = p.savePostMappings(plan.posts, txs)
+  if err != nil {
+    p.api.Log.Warn("Failed to save post mappings", "error", err.Error())
+  }
+
+  // Remember move for undoing it
+  err = p.saveMoveRecord(plan.userId, plan.srcPosts, txs, tombstoneIds)
+  if err != nil {
+    p.api.Log.Warn("Failed to save move record", "error", err.Error())
+  }
+  p.recordAuditEntry(plan, txs)
+  p.notifyAuthors(plan, txs)
+  p.rewriteLinks(plan, txs)
   return nil
 }
 
+func (p *Plug) recordAuditEntry(plan *movePlan, txs []*transaction) {
+  err := p.saveAuditEntry(plan, txs)
+  if err != nil {
+    p.api.Log.Error(
+      "Failed to save audit entry",
+      "operation", plan.operationId, "error", err.Error(),
+    )
+  }
+}
+
+func (p *Plug) finishOperation(plan *movePlan) {
+  plan.op.Done = true
+  p.persistOperation(plan)
+}
+
+// Roll back and keep what couldn't be rolled back for retries
+func (p *Plug) abortOperation(
+  plan *movePlan, txs []*transaction, partial bool, err error,
+) error {
+  err = p.abortMove(txs, partial, err)
+  p.persistOperation(plan)
+  return err
+}
+
+func (p *Plug) abortMove(txs []*transaction, partial bool, err error) error {
+  p.api.Log.Error("Moving messages failed", "error", err.Error())
+  for _, tx := range(txs) { p.rollback(tx) }
+  if partial { return i18n.NewError(i18n.MsgErrorMoveIncomplete) }
+  return i18n.NewError(i18n.MsgErrorMoveFailed)
+}
+
 func (p *Plug) getPostsFromIds(postIds []string) ([]*model.Post, error) {
   posts := make([]*model.Post, 0, len(postIds))
   for _, postId := range(postIds) {
@@ -66,50 +239,65 @@ func (p *Plug) getPostsFromIds(postIds []string) ([]*model.Post, error) {
 }
 
 func (p *Plug) assertSourcePermissions(
-  userId string, post *model.Post, tgtChannel *model.Channel,
+  userId string, post *model.Post, posts []*model.Post,
+  tgtChannel *model.Channel, copy bool,
 ) error {
   p.api.Log.Debug("Checking source permissions")
+  srcChannel, err := p.api.Channel.Get(post.ChannelId)
+  if err != nil { return err }
 
-  // Check message delete permission
-  var perm *model.Permission
-  switch post.UserId {
-    case userId: perm = model.PermissionDeletePost
-    default: perm = model.PermissionDeleteOthersPosts
+  // Check channel read permission for copying and conversations
+  direct := srcChannel.IsGroupOrDirect()
+  if copy || direct {
+    if !p.api.User.HasPermissionToChannel(
+      userId, post.ChannelId, model.PermissionReadChannel,
+    ) {
+      return i18n.NewError(i18n.MsgErrorPermissionCopy, "PostId", post.Id)
+    }
+  }
+
+  // Participants of conversations consent to moves out of them instead of
+  // requiring permissions
+  consent := direct && srcChannel.Id != tgtChannel.Id
+  canDelete := func(post *model.Post) bool {
+    return consent || p.canDeletePost(userId, post)
   }
-  if !p.api.User.HasPermissionToChannel(userId, post.ChannelId, perm) {
+
+  // Check message delete permission
+  if !copy && !canDelete(post) {
     return i18n.NewError(i18n.MsgErrorPermissionMessage, "PostId", post.Id)
   }
 
   // Check thread delete permission
-  if post.RootId == "" {
-    threadPosts, err := p.getThreadPosts(post.Id)
-    if err != nil { return err }
-    for _, reply := range(threadPosts) {
-      var perm *model.Permission
-      switch reply.UserId {
-        case userId: perm = model.PermissionDeletePost
-        default: perm = model.PermissionDeleteOthersPosts
-      }
-      if !p.api.User.HasPermissionToChannel(userId, reply.ChannelId, perm) {
+  if !copy && post.RootId == "" {
+    for _, reply := range(posts) {
+      if !canDelete(reply) {
         return i18n.NewError(i18n.MsgErrorPermissionReplies, "PostId", post.Id)
       }
     }
   }
 
-  // Check channel restrictions
-  if post.ChannelId != tgtChannel.Id {
-    srcChannel, err := p.api.Channel.Get(post.ChannelId)
-    if err != nil { return err }
-    if srcChannel.TeamId != tgtChannel.TeamId {
+  // Check team restrictions
+  if srcChannel.TeamId != "" && tgtChannel.TeamId != "" &&
+    srcChannel.TeamId != tgtChannel.TeamId {
+    if !p.getConfiguration().AllowCrossTeam {
       return i18n.NewError(i18n.MsgErrorOtherTeam)
     }
-    if srcChannel.Type != model.ChannelTypeOpen {
-      return i18n.NewError(i18n.MsgErrorPrivateChannel)
-    }
+    err := p.assertTeamMember(userId, tgtChannel.TeamId)
+    if err != nil { return err }
   }
   return nil
 }
 
+func (p *Plug) canDeletePost(userId string, post *model.Post) bool {
+  var perm *model.Permission
+  switch post.UserId {
+    case userId: perm = model.PermissionDeletePost
+    default: perm = model.PermissionDeleteOthersPosts
+  }
+  return p.api.User.HasPermissionToChannel(userId, post.ChannelId, perm)
+}
+
 func (p *Plug) getThreadPosts(
   postId string,
 ) ([]*model.Post, error) {
@@ -136,89 +324,175 @@ func (p *Plug) assertTargetPermissions(
   return nil
 }
 
-func (p *Plug) movePost(
-  userId string, source *model.Post, channel *model.Channel, root *model.Post,
+func (p *Plug) assertRolePermissions(
+  userId string, tgtChannel *model.Channel,
 ) error {
-  p.api.Log.Debug(
-    "Moving post", "post", source, "channel", channel, "thread", root,
-  )
+  p.api.Log.Debug("Checking role permissions")
+  allowed := p.getConfiguration().allowedRoles()
+  if allowed == nil { return nil }
 
-  // Construct post list
-  var posts []*model.Post
-  if source.RootId == "" {
-    var err error
-    posts, err = p.getThreadPosts(source.Id)
-    if err != nil { return err }
-  } else {
-    posts = []*model.Post{ source }
+  // Collect system, team and channel roles
+  user, err := p.api.User.Get(userId)
+  if err != nil { return err }
+  roles := strings.Fields(user.Roles)
+  if tgtChannel.TeamId != "" {
+    member, err := p.api.Team.GetMember(tgtChannel.TeamId, userId)
+    if err == nil { roles = append(roles, strings.Fields(member.Roles)...) }
   }
-  p.api.Log.Debug("Retrieved post list", "posts", posts)
+  member, err := p.api.Channel.GetMember(tgtChannel.Id, userId)
+  if err == nil { roles = append(roles, strings.Fields(member.Roles)...) }
 
-  // Copy posts
-  for _, post := range posts {
-    // Copy post
-    newPost := post.Clone()
-    newPost.ChannelId, newPost.RootId, newPost.Id = channel.Id, "", ""
-    newPost.ReplyCount = 0
-    if root != nil { newPost.RootId = root.Id }
+  // Check for allowed role
+  for _, role := range(roles) {
+    for _, allowedRole := range(allowed) {
+      if role == allowedRole { return nil }
+    }
+  }
+  return i18n.NewError(i18n.MsgErrorPermissionRole)
+}
 
-    // Copy attachments
-    newPost.FileIds = make([]string, 0, len(post.FileIds))
-    for _, fileId := range post.FileIds {
-      // Load old file
-      info, err := p.api.File.GetInfo(fileId)
-      if err != nil { return err }
-      bytes, err := p.api.File.Get(fileId)
+func (p *Plug) assertPostLimit(posts [][]*model.Post) error {
+  limit := p.getConfiguration().MaxPosts
+  if limit == 0 { return nil }
+  if countPosts(posts) > limit {
+    return i18n.NewError(i18n.MsgErrorTooManyPosts, "Max", strconv.Itoa(limit))
+  }
+  return nil
+}
+
+func countPosts(posts [][]*model.Post) int {
+  count := 0
+  for _, group := range(posts) { count += len(group) }
+  return count
+}
+
+// Get the post or, for root posts, the whole thread
+func (p *Plug) getMovedPosts(source *model.Post) ([]*model.Post, error) {
+  if source.RootId == "" { return p.getThreadPosts(source.Id) }
+  return []*model.Post{ source }, nil
+}
+
+func (p *Plug) copyPosts(
+  tx *transaction, operationId string,
+  userId string, posts []*model.Post, channel *model.Channel, root *model.Post,
+  copy bool,
+) error {
+  // Copy attachments, sharing the stored files and their previews
+  var filesLock sync.Mutex
+  fileIds := make([][]string, len(posts))
+  tasks := make([]func(context.Context) error, 0, len(posts))
+  for i, post := range(posts) {
+    fileIds[i] = []string{}
+    if _, ok := tx.resumed(post.Id); ok || len(post.FileIds) == 0 { continue }
+    i, post := i, post
+    tasks = append(tasks, func(ctx context.Context) error {
+      // Files can only be attached to posts of their creator
+      ids, err := p.api.File.CopyInfos(post.FileIds, post.UserId)
       if err != nil { return err }
+      if !tx.addFiles(ids) {
+        p.api.Log.Warn("Leaving unattached files behind", "files", ids)
+        return ctx.Err()
+      }
+      filesLock.Lock()
+      fileIds[i] = ids
+      filesLock.Unlock()
+      return nil
+    })
+  }
+  err := p.runParallel(tasks)
+  if err != nil { return err }
+  p.api.Log.Debug("Copied attachments", "attachments", fileIds)
 
-      // Create new file
-      newInfo, err := p.api.File.Upload(bytes, channel.Id, info.Name)
+  // Create posts in order
+  srcChannel, err := p.api.Channel.Get(posts[0].ChannelId)
+  if err != nil { return err }
+  newPosts := make([]*model.Post, 0, len(posts))
+  for i, post := range posts {
+    // Pick up post copied before a restart
+    if newId, ok := tx.resumed(post.Id); ok {
+      newPost, err := p.api.Post.GetPost(newId)
       if err != nil { return err }
-      newPost.FileIds = append(newPost.FileIds, newInfo.Id)
+      tx.addPost(newPost)
+      newPosts = append(newPosts, newPost)
+      if root == nil { root = newPost }
+      continue
     }
-    p.api.Log.Debug("Copied attachments", "attachments", newPost.FileIds)
 
-    // Add move event to history
-    addMoveHistoryElement(newPost, map[string]any{
+    // Copy post
+    newPost := post.Clone()
+    newPost.ChannelId, newPost.RootId, newPost.Id = channel.Id, "", ""
+    newPost.ReplyCount = 0
+    newPost.FileIds = fileIds[i]
+    if root != nil { newPost.RootId = root.Id }
+
+    // Add event to history
+    element := map[string]any{
       "timestamp": time.Now().Unix(),
+      "operation": operationId,
       "by_user": userId,
+      "from_team": srcChannel.TeamId,
       "from_channel": post.ChannelId,
       "from_thread": post.RootId,
-    })
+    }
+    if copy {
+      link, err := p.getPermalink(post)
+      if err != nil { return err }
+      element["from_post"], element["from_link"] = post.Id, link
+      addHistoryElement(newPost, "copy_history", element)
+    } else {
+      addHistoryElement(newPost, "move_history", element)
+    }
 
     // Create new post
-    err := p.api.Post.CreatePost(newPost)
+    err := p.createMovedPost(newPost)
     if err != nil { return err }
+    tx.addPost(newPost)
+    tx.created(post.Id, newPost.Id)
+    newPosts = append(newPosts, newPost)
     p.api.Log.Debug("Created new post", "post", newPost)
 
-    // Copy reactions
-    reactions, err := p.api.Post.GetReactions(post.Id)
-    if err != nil { return err }
-    for _, reaction := range(reactions) {
-      reaction.PostId = newPost.Id
-      err := p.api.Post.AddReaction(reaction)
-      if err != nil { return err }
-      p.api.Log.Debug("Added reaction", "reaction", reaction)
-    }
-
     // Set root post if nil
     if root == nil {
       root = newPost
       p.api.Log.Debug("Set post as root for further posts")
     }
   }
-  
-  // Delete original post
-  err := p.api.Post.DeletePost(source.Id)
-  if err != nil { return err }
-  p.api.Log.Debug("Deleted original post")
-  return nil
+
+  // Copy reactions
+  tasks = tasks[:0]
+  for i, post := range(posts) {
+    post, newPost := post, newPosts[i]
+    tasks = append(tasks, func(ctx context.Context) error {
+      reactions, err := p.api.Post.GetReactions(post.Id)
+      if err != nil { return err }
+      for _, reaction := range(reactions) {
+        if ctx.Err() != nil { return ctx.Err() }
+        reaction.PostId = newPost.Id
+        err := p.api.Post.AddReaction(reaction)
+        if err != nil { return err }
+
+        // Take back reactions added after the transaction was rolled back
+        if !tx.addReaction(reaction) {
+          err := p.api.Post.RemoveReaction(reaction)
+          if err != nil {
+            p.api.Log.Warn("Failed to remove reaction", "error", err.Error())
+          }
+          return ctx.Err()
+        }
+        p.api.Log.Debug("Added reaction", "reaction", reaction)
+      }
+      return nil
+    })
+  }
+  return p.runParallel(tasks)
 }
 
-func addMoveHistoryElement(post *model.Post, element map[string]any) error {
+func addHistoryElement(
+  post *model.Post, key string, element map[string]any,
+) error {
   // Get and deserialize history
   var history []map[string]any
-  if historyString, ok := post.GetProp("move_history").(string); ok {
+  if historyString, ok := post.GetProp(key).(string); ok {
     err := json.Unmarshal([]byte(historyString), &history)
     if err != nil { return err }
   }
@@ -230,7 +504,7 @@ func addMoveHistoryElement(post *model.Post, element map[string]any) error {
   // Serialize and set history
   historyBytes, err := json.Marshal(history)
   if err != nil { return err }
-  post.AddProp("move_history", string(historyBytes))
+  post.AddProp(key, string(historyBytes))
 
   return nil
 }
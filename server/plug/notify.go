@@ -0,0 +1,80 @@
+package plug
+
+import (
+  "strconv"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+func optOutKey(userId string) string {
+  return "optout_" + userId
+}
+
+func (p *Plug) setNotificationsEnabled(userId string, enabled bool) error {
+  if enabled { return p.api.KV.Delete(optOutKey(userId)) }
+  _, err := p.api.KV.Set(optOutKey(userId), true)
+  return err
+}
+
+func (p *Plug) notifyAuthors(plan *movePlan, txs []*transaction) {
+  if !p.getConfiguration().EnableAuthorNotifications { return }
+
+  // Group moved posts by author, remembering the first new post
+  counts := make(map[string]int)
+  firstPosts := make(map[string]*model.Post)
+  authorIds := make([]string, 0)
+  for i, posts := range(plan.posts) {
+    for j, post := range(posts) {
+      if post.UserId == plan.userId { continue }
+      if counts[post.UserId] == 0 {
+        authorIds = append(authorIds, post.UserId)
+        firstPosts[post.UserId] = txs[i].posts[j]
+      }
+      counts[post.UserId]++
+    }
+  }
+
+  // Send direct messages
+  for _, authorId := range(authorIds) {
+    err := p.notifyAuthor(
+      authorId, plan, counts[authorId], firstPosts[authorId],
+    )
+    if err != nil {
+      p.api.Log.Warn(
+        "Failed to notify author", "author", authorId, "error", err.Error(),
+      )
+    }
+  }
+}
+
+func (p *Plug) notifyAuthor(
+  authorId string, plan *movePlan, count int, newPost *model.Post,
+) error {
+  // Respect opt-out and skip bots
+  var optOut bool
+  err := p.api.KV.Get(optOutKey(authorId), &optOut)
+  if err != nil { return err }
+  if optOut { return nil }
+  author, err := p.api.User.Get(authorId)
+  if err != nil { return err }
+  if author.IsBot { return nil }
+
+  // Construct message in author's language
+  mover, err := p.api.User.Get(plan.userId)
+  if err != nil { return err }
+  link, err := p.getPermalink(newPost)
+  if err != nil { return err }
+  message := p.i18n.User(authorId).Template(
+    i18n.MsgNotification, map[string]string{
+      "UserName": mover.Username,
+      "Count": strconv.Itoa(count),
+      "ChannelName": plan.tgtChannel.Name,
+      "Link": link,
+    },
+  )
+
+  // Send message
+  return p.api.Post.DM(p.botId, authorId, &model.Post{ Message: message })
+}
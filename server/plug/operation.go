@@ -0,0 +1,104 @@
+package plug
+
+import (
+  "sort"
+  "time"
+  "strings"
+  "strconv"
+  "crypto/sha256"
+  "encoding/hex"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+// Time during which repeating a finished move has no effect
+const retryWindow = 10 * time.Minute
+
+// Progress of a move, shared by retries of the same command
+type operation struct {
+  Id string `json:"id"`
+  Created map[string]string `json:"created"`
+  Deleted []string `json:"deleted"`
+  Done bool `json:"done"`
+
+  // Sources are kept since deleted posts can't be fetched on retries
+  SrcPosts []*model.Post `json:"src_posts"`
+  Posts [][]*model.Post `json:"posts"`
+}
+
+// Key identifying a move by its user, sources and target
+func operationKey(
+  userId, channelId, targetPostId string, move *args.Move,
+) string {
+  sources := append([]string{}, move.Sources...)
+  sort.Strings(sources)
+  parts := append([]string{
+    userId, channelId, targetPostId, strconv.FormatBool(move.Copy),
+  }, sources...)
+  hash := sha256.Sum256([]byte(strings.Join(parts, " ")))
+  return "op_" + hex.EncodeToString(hash[:16])
+}
+
+// Load operation, starting a new one with the given ID if there is none
+func (p *Plug) loadOperation(key, id string) (*operation, error) {
+  var op *operation
+  err := p.api.KV.Get(key, &op)
+  if err != nil { return nil, err }
+  if op == nil { op = &operation{ Id: id } }
+  if op.Created == nil { op.Created = make(map[string]string) }
+  return op, nil
+}
+
+// Whether sources were deleted already, so the plan has to be restored
+func (op *operation) partlyDeleted() bool {
+  return len(op.Deleted) > 0 && len(op.SrcPosts) > 0
+}
+
+func assertNotDone(op *operation, copy bool) error {
+  if !op.Done { return nil }
+  if copy { return i18n.NewError(i18n.MsgErrorAlreadyCopied) }
+  return i18n.NewError(i18n.MsgErrorAlreadyMoved)
+}
+
+// Let the transaction skip and record posts of the operation
+func (p *Plug) trackOperation(tx *transaction, plan *movePlan) {
+  tx.done = plan.op.Created
+  tx.onCreate = func(oldId, newId string) {
+    plan.op.Created[oldId] = newId
+    p.persistOperation(plan)
+    if plan.job != nil { p.reportJobProgress(plan.job, len(plan.op.Created)) }
+  }
+}
+
+func (p *Plug) recordDeletion(plan *movePlan, postId string) {
+  plan.op.Deleted = append(plan.op.Deleted, postId)
+  p.persistOperation(plan)
+}
+
+// Keep failed operations for retries and finished ones for a while
+func (p *Plug) persistOperation(plan *movePlan) {
+  var err error
+  switch {
+    case plan.op.Done:
+      err = p.api.KV.SetWithExpiry(plan.opKey, plan.op, retryWindow)
+    case len(plan.op.Created) == 0 && len(plan.op.Deleted) == 0:
+      err = p.api.KV.Delete(plan.opKey)
+    default:
+      _, err = p.api.KV.Set(plan.opKey, plan.op)
+  }
+  if err != nil {
+    p.api.Log.Warn(
+      "Failed to save operation", "operation", plan.op.Id,
+      "error", err.Error(),
+    )
+  }
+}
+
+// Whether a post still exists, e.g. after being deleted before a restart
+func (p *Plug) postExists(postId string) bool {
+  _, err := p.api.Post.GetPost(postId)
+  return err == nil
+}
@@ -0,0 +1,55 @@
+package plug
+
+import (
+  "testing"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+func TestOperationKey(t *testing.T) {
+  key := func(userId, channelId, targetId string, move *args.Move) string {
+    return operationKey(userId, channelId, targetId, move)
+  }
+  base := key("user", "channel", "", &args.Move{
+    Sources: []string{ "a", "b" },
+  })
+
+  // Retries of the same move share their key
+  same := key("user", "channel", "", &args.Move{
+    Sources: []string{ "b", "a" }, DryRun: true,
+  })
+  if same != base { t.Errorf("source order changed key: %s, %s", base, same) }
+
+  // Every other move gets a key of its own
+  others := map[string]string{
+    "user": key("other", "channel", "", &args.Move{
+      Sources: []string{ "a", "b" },
+    }),
+    "channel": key("user", "other", "", &args.Move{
+      Sources: []string{ "a", "b" },
+    }),
+    "thread": key("user", "channel", "root", &args.Move{
+      Sources: []string{ "a", "b" },
+    }),
+    "sources": key("user", "channel", "", &args.Move{
+      Sources: []string{ "a" },
+    }),
+    "copy": key("user", "channel", "", &args.Move{
+      Sources: []string{ "a", "b" }, Copy: true,
+    }),
+  }
+  for name, other := range(others) {
+    if other == base { t.Errorf("different %s produced the same key", name) }
+  }
+}
+
+func TestPartlyDeleted(t *testing.T) {
+  op := &operation{ Deleted: []string{ "a" } }
+  if op.partlyDeleted() { t.Errorf("operation without sources resumed") }
+  op.SrcPosts = []*model.Post{{ Id: "a" }, { Id: "b" }}
+  if !op.partlyDeleted() { t.Errorf("partly deleted operation not resumed") }
+  op.Deleted = nil
+  if op.partlyDeleted() { t.Errorf("untouched operation resumed") }
+}
@@ -1,6 +1,9 @@
 package plug
 
 import (
+  "sync"
+
+  "github.com/mattermost/mattermost-server/v6/model"
   "github.com/mattermost/mattermost-server/v6/plugin"
   pluginapi "github.com/mattermost/mattermost-plugin-api"
 
@@ -12,6 +15,10 @@ type Plug struct {
 
   api *pluginapi.Client
   i18n *i18n.I18n
+  botId string
+
+  configLock sync.RWMutex
+  config *configuration
 }
 
 func New() *Plug {
@@ -28,6 +35,19 @@ func (p *Plug) OnActivate() error {
   p.i18n, err = i18n.New(p.API)
   if err != nil { return err }
 
+  // Create bot for notifications
+  p.botId, err = p.api.Bot.EnsureBot(&model.Bot{
+    Username: "move",
+    DisplayName: "Move",
+    Description: "Notifies users about moved messages.",
+  })
+  if err != nil { return err }
+
   // Create command
-  return p.api.SlashCommand.Register(p.createCommand("move"))
+  err = p.api.SlashCommand.Register(p.createCommand("move"))
+  if err != nil { return err }
+
+  // Resume interrupted background moves
+  go p.resumeJobs()
+  return nil
 }
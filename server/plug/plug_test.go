@@ -0,0 +1,39 @@
+package plug
+
+import (
+  "testing"
+
+  "github.com/stretchr/testify/mock"
+  "github.com/mattermost/mattermost-server/v6/model"
+  "github.com/mattermost/mattermost-server/v6/plugin/plugintest"
+  pluginapi "github.com/mattermost/mattermost-plugin-api"
+)
+
+// Plugin backed by a mocked server API that accepts any log calls
+func newTestPlug(t *testing.T) (*Plug, *plugintest.API) {
+  api := &plugintest.API{}
+  levels := []string{ "LogDebug", "LogInfo", "LogWarn", "LogError" }
+  for _, level := range(levels) {
+    for count := 1; count <= 11; count += 2 {
+      arguments := make([]any, count)
+      for i := range(arguments) { arguments[i] = mock.Anything }
+      api.On(level, arguments...).Maybe()
+    }
+  }
+  t.Cleanup(func() { api.AssertExpectations(t) })
+
+  p := New()
+  p.SetAPI(api)
+  p.api = pluginapi.NewClient(api, nil)
+  return p, api
+}
+
+// Post list ordered newest first like the server returns it
+func newPostList(posts ...*model.Post) *model.PostList {
+  list := model.NewPostList()
+  for i := len(posts) - 1; i >= 0; i-- {
+    list.AddPost(posts[i])
+    list.AddOrder(posts[i].Id)
+  }
+  return list
+}
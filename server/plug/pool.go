@@ -0,0 +1,69 @@
+package plug
+
+import (
+  "sync"
+  "time"
+  "errors"
+  "context"
+)
+
+var errStepTimeout = errors.New("step timed out")
+
+// Run tasks with limited concurrency, returning the first error and starting
+// no further tasks after it. Tasks exceeding the step timeout are reported as
+// failed and their context is canceled, but they can't be stopped and have to
+// check it themselves. They keep their slot until they really return.
+func (p *Plug) runParallel(tasks []func(context.Context) error) error {
+  config := p.getConfiguration()
+  limit := config.Concurrency
+  if limit < 1 { limit = 1 }
+  timeout := config.stepTimeout()
+
+  var wg sync.WaitGroup
+  var lock sync.Mutex
+  var firstErr error
+  fail := func(err error) {
+    lock.Lock()
+    defer lock.Unlock()
+    if firstErr == nil { firstErr = err }
+  }
+  failed := func() bool {
+    lock.Lock()
+    defer lock.Unlock()
+    return firstErr != nil
+  }
+  slots := make(chan struct{}, limit)
+  for _, task := range(tasks) {
+    task := task
+    slots <- struct{}{}
+    if failed() {
+      <-slots
+      break
+    }
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      ctx, cancel := context.WithCancel(context.Background())
+      defer cancel()
+
+      // Record errors before giving up the slot
+      done := make(chan struct{})
+      go func() {
+        defer func() { <-slots }()
+        defer close(done)
+        err := task(ctx)
+        if err != nil { fail(err) }
+      }()
+      if timeout <= 0 {
+        <-done
+        return
+      }
+      select {
+        case <-done:
+        case <-time.After(timeout): fail(errStepTimeout)
+      }
+    }()
+  }
+  wg.Wait()
+  return firstErr
+}
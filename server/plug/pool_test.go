@@ -0,0 +1,53 @@
+package plug
+
+import (
+  "errors"
+  "testing"
+  "context"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+)
+
+func TestRunParallelStopsAfterError(t *testing.T) {
+  p, _ := newTestPlug(t)
+  p.config = &configuration{ Concurrency: 1 }
+  failure := errors.New("failure")
+  started := false
+  err := p.runParallel([]func(context.Context) error{
+    func(ctx context.Context) error { return failure },
+    func(ctx context.Context) error { started = true; return nil },
+  })
+  if err != failure { t.Errorf("expected %v, got %v", failure, err) }
+  if started { t.Errorf("task started after failure") }
+}
+
+func TestRunParallelCancelsTimedOutTasks(t *testing.T) {
+  p, _ := newTestPlug(t)
+  p.config = &configuration{ Concurrency: 2, StepTimeout: 1 }
+  canceled := make(chan struct{})
+  err := p.runParallel([]func(context.Context) error{
+    func(ctx context.Context) error {
+      <-ctx.Done()
+      close(canceled)
+      return ctx.Err()
+    },
+  })
+  if err != errStepTimeout { t.Errorf("expected timeout, got %v", err) }
+  <-canceled
+}
+
+func TestClosedTransaction(t *testing.T) {
+  p, api := newTestPlug(t)
+  tx := newTransaction()
+  api.On("DeletePost", "new").Return(nil).Once()
+  tx.addPost(&model.Post{ Id: "new" })
+  p.rollback(tx)
+
+  // Steps finishing late must clean up after themselves
+  if tx.addFiles([]string{ "file" }) {
+    t.Errorf("files recorded after rollback")
+  }
+  if tx.addReaction(&model.Reaction{ PostId: "new" }) {
+    t.Errorf("reaction recorded after rollback")
+  }
+}
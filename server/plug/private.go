@@ -0,0 +1,131 @@
+package plug
+
+import (
+  "strconv"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+const (
+  membersPerPage = 200
+  maxListedUsers = 10
+)
+
+// Check that moving out of a private channel doesn't expose its messages
+func (p *Plug) assertPrivateAudience(
+  plan *movePlan, srcChannel *model.Channel,
+) error {
+  tgtChannel := plan.tgtChannel
+  if srcChannel.Id == tgtChannel.Id { return nil }
+
+  // Conversations are handled by their participants' consent
+  if srcChannel.IsGroupOrDirect() {
+    return p.addConsentUsers(plan, srcChannel.Id)
+  }
+  if tgtChannel.IsGroupOrDirect() {
+    return p.assertConversationAudience(srcChannel, tgtChannel)
+  }
+  if srcChannel.Type == model.ChannelTypeOpen { return nil }
+  if p.getConfiguration().AllowPrivateChannels { return nil }
+
+  // Find users who would newly see the messages
+  var exposed []string
+  if tgtChannel.Type != model.ChannelTypeOpen {
+    var err error
+    exposed, err = p.getExposedUsers(srcChannel.Id, tgtChannel.Id)
+    if err != nil { return err }
+    if len(exposed) == 0 { return nil }
+  }
+
+  // Let channel admins force the move
+  if plan.move.Force && p.api.User.HasPermissionToChannel(
+    plan.userId, srcChannel.Id, model.PermissionManageChannelRoles,
+  ) {
+    p.api.Log.Info(
+      "Forcing move out of private channel",
+      "user", plan.userId, "channel", srcChannel.Id, "exposed", exposed,
+    )
+    plan.forced = true
+    return nil
+  }
+
+  // Refuse move
+  if tgtChannel.Type == model.ChannelTypeOpen {
+    return i18n.NewError(i18n.MsgErrorPrivateToPublic)
+  }
+  users, err := p.formatUserIds(exposed)
+  if err != nil { return err }
+  return i18n.NewError(i18n.MsgErrorPrivateAudience, "Users", users)
+}
+
+// Require participants of conversations to see the messages already
+func (p *Plug) assertConversationAudience(
+  srcChannel, tgtChannel *model.Channel,
+) error {
+  members, err := p.listChannelMembers(tgtChannel.Id)
+  if err != nil { return err }
+  var srcMembers []string
+  if srcChannel.Type != model.ChannelTypeOpen {
+    srcMembers, err = p.listChannelMembers(srcChannel.Id)
+    if err != nil { return err }
+  }
+  exposed := make([]string, 0)
+  for _, userId := range(members) {
+    if srcChannel.Type == model.ChannelTypeOpen {
+      member, err := p.api.Team.GetMember(srcChannel.TeamId, userId)
+      if err == nil && member.DeleteAt == 0 { continue }
+    } else if containsString(srcMembers, userId) {
+      continue
+    }
+    exposed = append(exposed, userId)
+  }
+  if len(exposed) == 0 { return nil }
+  users, err := p.formatUserIds(exposed)
+  if err != nil { return err }
+  return i18n.NewError(i18n.MsgErrorConversationAudience, "Users", users)
+}
+
+// Members of the target channel who aren't members of the source channel
+func (p *Plug) getExposedUsers(srcChannelId, tgtChannelId string) (
+  []string, error,
+) {
+  srcMembers, err := p.listChannelMembers(srcChannelId)
+  if err != nil { return nil, err }
+  tgtMembers, err := p.listChannelMembers(tgtChannelId)
+  if err != nil { return nil, err }
+  exposed := make([]string, 0)
+  for _, userId := range(tgtMembers) {
+    if !containsString(srcMembers, userId) { exposed = append(exposed, userId) }
+  }
+  return exposed, nil
+}
+
+func (p *Plug) listChannelMembers(channelId string) ([]string, error) {
+  userIds := make([]string, 0)
+  for page := 0; ; page++ {
+    members, err := p.api.Channel.ListMembers(channelId, page, membersPerPage)
+    if err != nil { return nil, err }
+    for _, member := range(members) {
+      userIds = append(userIds, member.UserId)
+    }
+    if len(members) < membersPerPage { return userIds, nil }
+  }
+}
+
+// Mention users, abbreviating long lists
+func (p *Plug) formatUserIds(userIds []string) (string, error) {
+  users := make([]*model.User, 0, maxListedUsers)
+  for _, userId := range(userIds) {
+    if len(users) == maxListedUsers { break }
+    user, err := p.api.User.Get(userId)
+    if err != nil { return "", err }
+    users = append(users, user)
+  }
+  text := formatUsers(users)
+  if len(userIds) > len(users) {
+    text += " (+" + strconv.Itoa(len(userIds) - len(users)) + ")"
+  }
+  return text, nil
+}
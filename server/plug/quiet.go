@@ -0,0 +1,36 @@
+package plug
+
+import (
+  "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Message free of mentions and keywords used while creating posts
+const quietPlaceholder = "…"
+
+// Create post without triggering mention notifications if configured
+func (p *Plug) createMovedPost(post *model.Post) error {
+  if !p.getConfiguration().SuppressNotifications {
+    return p.api.Post.CreatePost(post)
+  }
+
+  // The server refuses to edit posts older than the edit time limit
+  if p.editTimeLimitExceeded(post) {
+    p.api.Log.Debug("Creating post with notifications due to edit limit")
+    return p.api.Post.CreatePost(post)
+  }
+
+  // Notifications are only sent on creation, so add message afterwards
+  message := post.Message
+  post.Message = quietPlaceholder
+  err := p.api.Post.CreatePost(post)
+  if err != nil { return err }
+  post.Message = message
+  err = p.api.Post.UpdatePost(post)
+  if err != nil {
+    if err := p.api.Post.DeletePost(post.Id); err != nil {
+      p.api.Log.Warn("Failed to delete post", "error", err.Error())
+    }
+    return err
+  }
+  return nil
+}
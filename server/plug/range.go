@@ -0,0 +1,87 @@
+package plug
+
+import (
+  "sort"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const rangePageSize = 200
+
+// Replace range by the messages it contains
+func (p *Plug) resolveRange(move *args.Move) error {
+  if move.Range == nil { return nil }
+  posts, err := p.getPostsFromIds([]string{ move.Range.First, move.Range.Last })
+  if err != nil { return err }
+  first, last := posts[0], posts[1]
+  if first.ChannelId != last.ChannelId {
+    return i18n.NewError(i18n.MsgErrorRangeChannels)
+  }
+  if first.CreateAt > last.CreateAt { first, last = last, first }
+
+  // Collect messages of the thread or channel
+  var candidates []*model.Post
+  threadId := first.RootId
+  if threadId == "" { threadId = first.Id }
+  thread := last.RootId == threadId && last.RootId != ""
+  if thread {
+    candidates, err = p.getThreadPosts(threadId)
+  } else {
+    candidates, err = p.getChannelPostsSince(first.ChannelId, first.CreateAt)
+  }
+  if err != nil { return err }
+  sort.Slice(candidates, func(i, j int) bool {
+    return candidates[i].CreateAt < candidates[j].CreateAt
+  })
+
+  // Moving the thread's root takes all replies along, so only select it if
+  // the range reaches the end of the thread
+  wholeThread := true
+  for _, post := range(candidates) {
+    if post.CreateAt > last.CreateAt && post.DeleteAt == 0 &&
+      !post.IsSystemMessage() {
+      wholeThread = false
+    }
+  }
+
+  // Select messages in range, leaving replies to their moved roots
+  selected := make([]string, 0)
+  for _, post := range(candidates) {
+    if post.CreateAt < first.CreateAt || post.CreateAt > last.CreateAt {
+      continue
+    }
+    if post.DeleteAt != 0 || post.IsSystemMessage() { continue }
+    if thread && post.Id == threadId && !wholeThread { continue }
+    if post.RootId != "" && containsString(selected, post.RootId) { continue }
+    selected = append(selected, post.Id)
+  }
+  if len(selected) == 0 { return i18n.NewError(i18n.MsgErrorNoMatches) }
+  p.api.Log.Debug("Resolved range", "range", move.Range, "posts", selected)
+  for _, postId := range(selected) {
+    if !containsString(move.Sources, postId) {
+      move.Sources = append(move.Sources, postId)
+    }
+  }
+  move.Range = nil
+  return nil
+}
+
+// Messages and replies of a channel created at or after the given time
+func (p *Plug) getChannelPostsSince(
+  channelId string, since int64,
+) ([]*model.Post, error) {
+  posts := make([]*model.Post, 0)
+  for page := 0; ; page++ {
+    list, err := p.api.Post.GetPostsForChannel(channelId, page, rangePageSize)
+    if err != nil { return nil, err }
+    pagePosts := list.ToSlice()
+    for _, post := range(pagePosts) {
+      if post.CreateAt >= since { posts = append(posts, post) }
+    }
+    if len(pagePosts) < rangePageSize { return posts, nil }
+    if pagePosts[len(pagePosts) - 1].CreateAt < since { return posts, nil }
+  }
+}
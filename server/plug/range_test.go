@@ -0,0 +1,67 @@
+package plug
+
+import (
+  "testing"
+  "reflect"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+func TestResolveRange(t *testing.T) {
+  post := func(id, rootId string, createAt int64) *model.Post {
+    return &model.Post{
+      Id: id, ChannelId: "channel", RootId: rootId, CreateAt: createAt,
+    }
+  }
+  join := post("join", "", 1)
+  join.Type = model.PostTypeJoinChannel
+  a, b, c := post("a", "", 2), post("b", "", 3), post("c", "b", 4)
+  d, e, f := post("d", "", 5), post("e", "b", 6), post("f", "b", 7)
+  posts := []*model.Post{ join, a, b, c, d, e, f }
+  thread := []*model.Post{ b, c, e, f }
+
+  tests := []struct {
+    name string
+    first, last string
+    sources []string
+    err *i18n.Message
+  }{
+    { "channel", "a", "d", []string{ "a", "b", "d" }, nil },
+    { "reversed", "d", "a", []string{ "a", "b", "d" }, nil },
+    { "reply to message", "c", "d", []string{ "c", "d" }, nil },
+    { "thread part", "c", "e", []string{ "c", "e" }, nil },
+    { "thread from root", "b", "e", []string{ "c", "e" }, nil },
+    { "whole thread", "b", "f", []string{ "b" }, nil },
+    { "system messages only", "join", "join", nil, i18n.MsgErrorNoMatches },
+  }
+  for _, test := range(tests) {
+    t.Run(test.name, func(t *testing.T) {
+      p, api := newTestPlug(t)
+      for _, post := range(posts) {
+        api.On("GetPost", post.Id).Return(post, nil).Maybe()
+      }
+      api.On("GetPostThread", "b").Return(newPostList(thread...), nil).Maybe()
+      api.On("GetPostsForChannel", "channel", 0, rangePageSize).
+        Return(newPostList(posts...), nil).Maybe()
+
+      move := &args.Move{
+        Range: &args.Range{ First: test.first, Last: test.last },
+      }
+      err := p.resolveRange(move)
+      if test.err != nil {
+        if err == nil || err.Error() != test.err.Other {
+          t.Fatalf("expected error %q, got %v", test.err.Other, err)
+        }
+        return
+      }
+      if err != nil { t.Fatalf("unexpected error: %v", err) }
+      if !reflect.DeepEqual(move.Sources, test.sources) {
+        t.Fatalf("expected %v, got %v", test.sources, move.Sources)
+      }
+      if move.Range != nil { t.Fatalf("range was not cleared") }
+    })
+  }
+}
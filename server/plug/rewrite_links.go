@@ -0,0 +1,175 @@
+package plug
+
+import (
+  "time"
+  "regexp"
+  "strconv"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+const (
+  rewriteLinksOff = "off"
+  rewriteLinksChannels = "channels"
+  rewriteLinksTeam = "team"
+  rewriteChannelsPerPage = 200
+  rewritePostsPerPage = 200
+)
+
+// Moved posts whose links need to be rewritten
+type linkRewrite struct {
+  userId string
+  operationId string
+  newIds map[string]string
+  since int64
+  permalinks map[string]string
+  linkExp *regexp.Regexp
+  failed int
+}
+
+func (p *Plug) rewriteLinks(plan *movePlan, txs []*transaction) {
+  mode := p.getConfiguration().RewriteLinks
+  if mode == "" || mode == rewriteLinksOff { return }
+
+  // Collect new IDs and affected channels
+  rewrite := &linkRewrite{
+    userId: plan.userId,
+    operationId: plan.operationId,
+    newIds: make(map[string]string),
+    since: model.GetMillis(),
+    permalinks: make(map[string]string),
+  }
+  channelIds := []string{ plan.tgtChannel.Id }
+  for i, posts := range(plan.posts) {
+    for j, post := range(posts) {
+      rewrite.newIds[post.Id] = txs[i].posts[j].Id
+      if post.CreateAt < rewrite.since { rewrite.since = post.CreateAt }
+    }
+    if !containsString(channelIds, plan.srcPosts[i].ChannelId) {
+      channelIds = append(channelIds, plan.srcPosts[i].ChannelId)
+    }
+  }
+  siteURL := *p.api.Configuration.GetConfig().ServiceSettings.SiteURL
+  rewrite.linkExp = regexp.MustCompile(
+    regexp.QuoteMeta(siteURL) +
+    `/(?:_redirect|[-a-z0-9]+)/pl/([a-z0-9]{26})`,
+  )
+
+  // Rewrite links in affected channels and optionally the rest of the team
+  // in the background, as this may take a while for old messages
+  teamId := plan.tgtChannel.TeamId
+  go func() {
+    for _, channelId := range(channelIds) {
+      p.rewriteLinksInChannel(rewrite, channelId)
+    }
+    if mode == rewriteLinksTeam && teamId != "" {
+      p.rewriteLinksInTeam(rewrite, teamId, channelIds)
+    }
+    p.reportFailedRewrites(rewrite)
+  }()
+}
+
+// Tell the mover about links that couldn't be updated
+func (p *Plug) reportFailedRewrites(rewrite *linkRewrite) {
+  if rewrite.failed == 0 { return }
+  localizer := p.i18n.User(rewrite.userId)
+  err := p.api.Post.DM(p.botId, rewrite.userId, &model.Post{
+    Message: localizer.Template(i18n.MsgRewriteFailed, map[string]string{
+      "Count": strconv.Itoa(rewrite.failed),
+    }),
+  })
+  if err != nil {
+    p.api.Log.Warn("Failed to report failed rewrites", "error", err.Error())
+  }
+}
+
+func (p *Plug) rewriteLinksInTeam(
+  rewrite *linkRewrite, teamId string, doneIds []string,
+) {
+  p.api.Log.Debug("Rewriting links in team", "team", teamId)
+  for page := 0; ; page++ {
+    channels, err := p.api.Channel.ListPublicChannelsForTeam(
+      teamId, page, rewriteChannelsPerPage,
+    )
+    if err != nil {
+      p.api.Log.Warn("Failed to list channels", "error", err.Error())
+      return
+    }
+    for _, channel := range(channels) {
+      if containsString(doneIds, channel.Id) { continue }
+      p.rewriteLinksInChannel(rewrite, channel.Id)
+    }
+    if len(channels) < rewriteChannelsPerPage { return }
+  }
+}
+
+func (p *Plug) rewriteLinksInChannel(rewrite *linkRewrite, channelId string) {
+  // Links can only occur in posts newer than the linked ones
+  for page := 0; ; page++ {
+    list, err := p.api.Post.GetPostsForChannel(
+      channelId, page, rewritePostsPerPage,
+    )
+    if err != nil {
+      p.api.Log.Warn("Failed to get posts", "error", err.Error())
+      return
+    }
+    posts := list.ToSlice()
+    for _, post := range(posts) {
+      if post.CreateAt >= rewrite.since { p.rewriteLinksInPost(rewrite, post) }
+    }
+    if len(posts) < rewritePostsPerPage { return }
+    if posts[len(posts) - 1].CreateAt < rewrite.since { return }
+  }
+}
+
+func (p *Plug) rewriteLinksInPost(rewrite *linkRewrite, post *model.Post) {
+  if post.DeleteAt != 0 { return }
+
+  // Replace links to moved posts
+  changed := false
+  message := rewrite.linkExp.ReplaceAllStringFunc(
+    post.Message, func(link string) string {
+      oldId := rewrite.linkExp.FindStringSubmatch(link)[1]
+      newLink, ok := p.getNewPermalink(rewrite, oldId)
+      if !ok { return link }
+      changed = true
+      return newLink
+    },
+  )
+  if !changed { return }
+  if p.editTimeLimitExceeded(post) {
+    rewrite.failed++
+    return
+  }
+
+  // Update post and record edit
+  post.Message = message
+  addHistoryElement(post, "link_history", map[string]any{
+    "timestamp": time.Now().Unix(),
+    "by_user": rewrite.userId,
+    "operation": rewrite.operationId,
+  })
+  err := p.api.Post.UpdatePost(post)
+  if err != nil {
+    rewrite.failed++
+    p.api.Log.Warn("Failed to rewrite links", "error", err.Error())
+  } else {
+    p.api.Log.Debug("Rewrote links", "post", post.Id)
+  }
+}
+
+func (p *Plug) getNewPermalink(
+  rewrite *linkRewrite, oldId string,
+) (string, bool) {
+  newId, ok := rewrite.newIds[oldId]
+  if !ok { return "", false }
+  if link, ok := rewrite.permalinks[newId]; ok { return link, true }
+  newPost, err := p.api.Post.GetPost(newId)
+  if err != nil { return "", false }
+  link, err := p.getPermalink(newPost)
+  if err != nil { return "", false }
+  rewrite.permalinks[newId] = link
+  return link, true
+}
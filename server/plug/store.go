@@ -0,0 +1,91 @@
+package plug
+
+import (
+  "sort"
+  "encoding/json"
+
+  pluginapi "github.com/mattermost/mattermost-plugin-api"
+)
+
+const kvListPageSize = 1000
+
+// Key lists kept to avoid scanning the whole KV store
+const (
+  auditIndexKey = "index_audit"
+  jobIndexKey = "index_job"
+)
+
+// Get sorted keys of an index, creating it from keys stored before the index
+// existed if necessary
+func (p *Plug) getIndex(indexKey, prefix string) ([]string, error) {
+  var keys *[]string
+  err := p.api.KV.Get(indexKey, &keys)
+  if err != nil { return nil, err }
+  if keys != nil { return *keys, nil }
+  var created []string
+  err = p.updateIndex(indexKey, prefix, func(keys []string) []string {
+    created = keys
+    return keys
+  })
+  return created, err
+}
+
+func (p *Plug) addToIndex(indexKey, prefix, key string) error {
+  return p.updateIndex(indexKey, prefix, func(keys []string) []string {
+    if containsString(keys, key) { return keys }
+    keys = append(keys, key)
+    sort.Strings(keys)
+    return keys
+  })
+}
+
+func (p *Plug) removeFromIndex(indexKey, prefix, key string) error {
+  return p.updateIndex(indexKey, prefix, func(keys []string) []string {
+    remaining := make([]string, 0, len(keys))
+    for _, other := range(keys) {
+      if other != key { remaining = append(remaining, other) }
+    }
+    return remaining
+  })
+}
+
+func (p *Plug) updateIndex(
+  indexKey, prefix string, update func([]string) []string,
+) error {
+  return p.api.KV.SetAtomicWithRetries(
+    indexKey, func(old []byte) (any, error) {
+      var keys []string
+      var err error
+      if old == nil {
+        keys, err = p.listKeys(prefix)
+      } else {
+        err = json.Unmarshal(old, &keys)
+      }
+      if err != nil { return nil, err }
+      return update(keys), nil
+    },
+  )
+}
+
+// List all keys with the given prefix in ascending order
+func (p *Plug) listKeys(prefix string) ([]string, error) {
+  keys := make([]string, 0)
+  for page := 0; ; page++ {
+    // Count unfiltered keys to detect the last page
+    count := 0
+    pageKeys, err := p.api.KV.ListKeys(
+      page, kvListPageSize,
+      pluginapi.WithChecker(func(string) (bool, error) {
+        count++
+        return true, nil
+      }),
+      pluginapi.WithPrefix(prefix),
+    )
+    if err != nil { return nil, err }
+    keys = append(keys, pageKeys...)
+    if count < kvListPageSize {
+      sort.Strings(keys)
+      return keys, nil
+    }
+  }
+}
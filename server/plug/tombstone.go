@@ -0,0 +1,103 @@
+package plug
+
+import (
+  "strconv"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+  "github.com/salatfreak/mattermost-plugin-move/server/args"
+)
+
+const tombstonePostType = model.PostCustomTypePrefix + "move_tombstone"
+
+// Messages moved away from the same channel or thread
+type tombstone struct {
+  channelId string
+  rootId string
+  count int
+  newPost *model.Post
+}
+
+func (p *Plug) leaveTombstones(
+  userId string, srcPosts []*model.Post, txs []*transaction,
+  tgtChannel *model.Channel, move *args.Move,
+) []string {
+  config := p.getConfiguration()
+  if !config.EnableTombstones { return nil }
+
+  // Group moved messages by source location
+  tombstones := make([]*tombstone, 0, len(srcPosts))
+  for i, post := range(srcPosts) {
+    var stone *tombstone
+    for _, other := range(tombstones) {
+      if other.channelId == post.ChannelId && other.rootId == post.RootId {
+        stone = other
+      }
+    }
+    if stone == nil {
+      stone = &tombstone{
+        channelId: post.ChannelId, rootId: post.RootId,
+        newPost: txs[i].posts[0],
+      }
+      tombstones = append(tombstones, stone)
+    }
+    stone.count += len(txs[i].posts)
+  }
+
+  // Create tombstone posts
+  postIds := make([]string, 0, len(tombstones))
+  for _, stone := range(tombstones) {
+    postId, err := p.leaveTombstone(userId, stone, tgtChannel, move.Tombstone)
+    if err != nil {
+      p.api.Log.Warn("Failed to leave tombstone", "error", err.Error())
+    } else if postId != "" {
+      postIds = append(postIds, postId)
+    }
+  }
+  return postIds
+}
+
+func (p *Plug) leaveTombstone(
+  userId string, stone *tombstone, tgtChannel *model.Channel, enable *bool,
+) (string, error) {
+  // Check whether tombstone is requested
+  channel, err := p.api.Channel.Get(stone.channelId)
+  if err != nil { return "", err }
+  if enable == nil {
+    var teamName string
+    if channel.TeamId != "" {
+      team, err := p.api.Team.Get(channel.TeamId)
+      if err != nil { return "", err }
+      teamName = team.Name
+    }
+    defaultEnable := p.getConfiguration().tombstoneDefault(teamName)
+    enable = &defaultEnable
+  }
+  if !*enable { return "", nil }
+
+  // Construct message
+  user, err := p.api.User.Get(userId)
+  if err != nil { return "", err }
+  link, err := p.getPermalink(stone.newPost)
+  if err != nil { return "", err }
+  message := p.i18n.Server().Template(i18n.MsgTombstone, map[string]string{
+    "Count": strconv.Itoa(stone.count),
+    "ChannelName": tgtChannel.Name,
+    "UserName": user.Username,
+    "Link": link,
+  })
+
+  // Create post
+  post := &model.Post{
+    UserId: userId,
+    ChannelId: stone.channelId,
+    RootId: stone.rootId,
+    Type: tombstonePostType,
+    Message: message,
+  }
+  err = p.api.Post.CreatePost(post)
+  if err != nil { return "", err }
+  p.api.Log.Debug("Left tombstone", "post", post)
+  return post.Id, nil
+}
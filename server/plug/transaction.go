@@ -0,0 +1,102 @@
+package plug
+
+import (
+  "sync"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+)
+
+// Everything created while copying a message, so it can be reverted
+type transaction struct {
+  lock sync.Mutex
+  posts []*model.Post
+  files []string
+  reactions []*model.Reaction
+
+  // Posts created before a restart and a hook for newly created ones
+  done map[string]string
+  onCreate func(oldId, newId string)
+
+  // Set once rolled back, so late parallel steps stop recording
+  closed bool
+}
+
+func newTransaction() *transaction {
+  return &transaction{}
+}
+
+// New post ID of a post copied before a restart
+func (t *transaction) resumed(postId string) (string, bool) {
+  newId, ok := t.done[postId]
+  return newId, ok
+}
+
+func (t *transaction) created(oldId, newId string) {
+  if t.onCreate != nil { t.onCreate(oldId, newId) }
+}
+
+// Record copied files, reporting false if the transaction is closed
+func (t *transaction) addFiles(fileIds []string) bool {
+  t.lock.Lock()
+  defer t.lock.Unlock()
+  if t.closed { return false }
+  t.files = append(t.files, fileIds...)
+  return true
+}
+
+func (t *transaction) addPost(post *model.Post) {
+  t.lock.Lock()
+  defer t.lock.Unlock()
+  t.posts = append(t.posts, post)
+
+  // Files are owned by the post once it is created
+  files := t.files[:0]
+  for _, fileId := range(t.files) {
+    if !containsString(post.FileIds, fileId) { files = append(files, fileId) }
+  }
+  t.files = files
+}
+
+// Record an added reaction, reporting false if the transaction is closed
+func (t *transaction) addReaction(reaction *model.Reaction) bool {
+  t.lock.Lock()
+  defer t.lock.Unlock()
+  if t.closed { return false }
+  t.reactions = append(t.reactions, reaction)
+  return true
+}
+
+func (p *Plug) rollback(tx *transaction) {
+  tx.lock.Lock()
+  defer tx.lock.Unlock()
+  tx.closed = true
+  p.api.Log.Debug(
+    "Rolling back transaction",
+    "posts", tx.posts, "files", tx.files, "reactions", tx.reactions,
+  )
+
+  // Remove reactions
+  for i := len(tx.reactions) - 1; i >= 0; i-- {
+    err := p.api.Post.RemoveReaction(tx.reactions[i])
+    if err != nil {
+      p.api.Log.Warn("Failed to remove reaction", "error", err.Error())
+    }
+  }
+
+  // Delete posts including their attachments
+  for i := len(tx.posts) - 1; i >= 0; i-- {
+    err := p.api.Post.DeletePost(tx.posts[i].Id)
+    if err != nil {
+      p.api.Log.Warn("Failed to delete post", "error", err.Error())
+      continue
+    }
+    for oldId, newId := range(tx.done) {
+      if newId == tx.posts[i].Id { delete(tx.done, oldId) }
+    }
+  }
+
+  // Report files that were never attached to a post
+  if len(tx.files) > 0 {
+    p.api.Log.Warn("Leaving unattached files behind", "files", tx.files)
+  }
+}
@@ -0,0 +1,46 @@
+package plug
+
+import (
+  "testing"
+  "reflect"
+
+  "github.com/stretchr/testify/mock"
+  "github.com/mattermost/mattermost-server/v6/model"
+)
+
+func TestRollback(t *testing.T) {
+  p, api := newTestPlug(t)
+  tx := newTransaction()
+  tx.done = map[string]string{ "old1": "new1", "old2": "new2" }
+  tx.addFiles([]string{ "file1", "file2" })
+  tx.addPost(&model.Post{ Id: "new1", FileIds: []string{ "file1" } })
+  tx.addPost(&model.Post{ Id: "new2" })
+  reaction := &model.Reaction{ PostId: "new1", EmojiName: "smile" }
+  tx.addReaction(reaction)
+
+  // Reactions go first, then posts in reverse order
+  deleted := make([]string, 0)
+  api.On("RemoveReaction", reaction).Return(nil).Once()
+  api.On("DeletePost", "new2").Return(&model.AppError{ Message: "failed" }).
+    Run(func(args mock.Arguments) { deleted = append(deleted, "new2") }).
+    Once()
+  api.On("DeletePost", "new1").Return(nil).
+    Run(func(args mock.Arguments) { deleted = append(deleted, "new1") }).
+    Once()
+  p.rollback(tx)
+
+  if !reflect.DeepEqual(deleted, []string{ "new2", "new1" }) {
+    t.Errorf("posts deleted in wrong order: %v", deleted)
+  }
+
+  // Only posts that are really gone are forgotten for retries
+  expected := map[string]string{ "old2": "new2" }
+  if !reflect.DeepEqual(tx.done, expected) {
+    t.Errorf("expected %v to be kept, got %v", expected, tx.done)
+  }
+
+  // Attached files are deleted along with their posts
+  if !reflect.DeepEqual(tx.files, []string{ "file2" }) {
+    t.Errorf("expected only unattached files, got %v", tx.files)
+  }
+}
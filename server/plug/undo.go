@@ -0,0 +1,207 @@
+package plug
+
+import (
+  "time"
+  "strconv"
+
+  "github.com/mattermost/mattermost-server/v6/model"
+
+  "github.com/salatfreak/mattermost-plugin-move/server/i18n"
+)
+
+type moveRecord struct {
+  Timestamp int64 `json:"timestamp"`
+  Sources []movedSource `json:"sources"`
+  Tombstones []string `json:"tombstones"`
+}
+
+type movedSource struct {
+  FromChannel string `json:"from_channel"`
+  FromThread string `json:"from_thread"`
+  PostIds []string `json:"post_ids"`
+}
+
+func undoKey(userId string) string {
+  return "undo_" + userId
+}
+
+func (p *Plug) saveMoveRecord(
+  userId string, srcPosts []*model.Post, txs []*transaction,
+  tombstoneIds []string,
+) error {
+  record := moveRecord{
+    Timestamp: model.GetMillis(),
+    Sources: make([]movedSource, 0, len(srcPosts)),
+    Tombstones: tombstoneIds,
+  }
+  for i, post := range(srcPosts) {
+    postIds := make([]string, 0, len(txs[i].posts))
+    for _, newPost := range(txs[i].posts) {
+      postIds = append(postIds, newPost.Id)
+    }
+    record.Sources = append(record.Sources, movedSource{
+      FromChannel: post.ChannelId,
+      FromThread: post.RootId,
+      PostIds: postIds,
+    })
+  }
+  _, err := p.api.KV.Set(undoKey(userId), record)
+  return err
+}
+
+func (p *Plug) runUndo(userId string, force bool) (int, error) {
+  p.api.Log.Debug("Running undo command", "user", userId, "force", force)
+
+  // Get last move
+  config := p.getConfiguration()
+  var record *moveRecord
+  err := p.api.KV.Get(undoKey(userId), &record)
+  if err != nil { return 0, err }
+  if record == nil || config.UndoWindow <= 0 {
+    return 0, i18n.NewError(i18n.MsgErrorNothingToUndo)
+  }
+  if time.Since(time.UnixMilli(record.Timestamp)) > config.undoWindow() {
+    minutes := strconv.Itoa(config.UndoWindow)
+    return 0, i18n.NewError(i18n.MsgErrorUndoExpired, "Minutes", minutes)
+  }
+
+  // Get moved posts and original channels
+  groups := make([][]*model.Post, 0, len(record.Sources))
+  channels := make([]*model.Channel, 0, len(record.Sources))
+  for _, source := range(record.Sources) {
+    posts, err := p.getPostsFromIds(source.PostIds)
+    if err != nil { return 0, err }
+    channel, err := p.api.Channel.Get(source.FromChannel)
+    if err != nil { return 0, err }
+    groups = append(groups, posts)
+    channels = append(channels, channel)
+  }
+
+  // Check permissions and new replies
+  for i, posts := range(groups) {
+    for _, post := range(posts) {
+      if !p.canDeletePost(userId, post) {
+        return 0, i18n.NewError(
+          i18n.MsgErrorPermissionMessage, "PostId", post.Id,
+        )
+      }
+    }
+    err := p.assertTargetPermissions(userId, channels[i])
+    if err != nil { return 0, err }
+    err = p.assertRolePermissions(userId, channels[i])
+    if err != nil { return 0, err }
+    if !force {
+      newReplies, err := p.hasNewReplies(posts, record.Timestamp)
+      if err != nil { return 0, err }
+      if newReplies { return 0, i18n.NewError(i18n.MsgErrorUndoNewReplies) }
+    }
+  }
+
+  // Carry new replies back along with moved threads, as deleting the root
+  // deletes them as well
+  for i, posts := range(groups) {
+    if posts[0].RootId != "" { continue }
+    groups[i], err = p.getThreadPosts(posts[0].Id)
+    if err != nil { return 0, err }
+  }
+
+  // Copy messages back
+  count := 0
+  txs := make([]*transaction, 0, len(groups))
+  operationIds := make([]string, 0, len(groups))
+  roots := make([]*model.Post, 0, len(groups))
+  for i, posts := range(groups) {
+    var root *model.Post
+    if threadId := record.Sources[i].FromThread; threadId != "" {
+      root, err = p.api.Post.GetPost(threadId)
+      if err != nil { root = nil } // Thread is gone, restore as new thread
+    }
+    tx := newTransaction()
+    txs = append(txs, tx)
+    operationIds = append(operationIds, model.NewId())
+    roots = append(roots, root)
+    err = p.copyPosts(
+      tx, operationIds[i], userId, posts, channels[i], root, false,
+    )
+    if err != nil { return 0, p.abortMove(txs, false, err) }
+    count += len(posts)
+  }
+
+  // Delete moved messages
+  for _, posts := range(groups) {
+    for j := len(posts) - 1; j >= 0; j-- {
+      err = p.api.Post.DeletePost(posts[j].Id)
+      if err != nil { return 0, p.abortUndo(groups, txs, err) }
+    }
+  }
+
+  // Remember new IDs of restored messages
+  err = p.savePostMappings(groups, txs)
+  if err != nil {
+    p.api.Log.Warn("Failed to save post mappings", "error", err.Error())
+  }
+
+  // Record undo in audit log
+  for i, posts := range(groups) {
+    err := p.saveUndoAuditEntry(
+      operationIds[i], userId, posts, txs[i], channels[i], roots[i],
+    )
+    if err != nil {
+      p.api.Log.Error("Failed to save audit entry", "error", err.Error())
+    }
+  }
+
+  // Remove tombstones
+  for _, postId := range(record.Tombstones) {
+    err = p.api.Post.DeletePost(postId)
+    if err != nil {
+      p.api.Log.Warn("Failed to delete tombstone", "error", err.Error())
+    }
+  }
+
+  // Forget move
+  err = p.api.KV.Delete(undoKey(userId))
+  if err != nil { return 0, err }
+  return count, nil
+}
+
+// Roll back restored copies of messages that weren't deleted yet, keeping
+// those whose moved version is gone
+func (p *Plug) abortUndo(
+  groups [][]*model.Post, txs []*transaction, err error,
+) error {
+  p.api.Log.Error("Undoing move failed", "error", err.Error())
+  partial := false
+  for i, tx := range(txs) {
+    revert := newTransaction()
+    for j, post := range(groups[i]) {
+      if p.postExists(post.Id) {
+        revert.posts = append(revert.posts, tx.posts[j])
+      } else {
+        partial = true
+      }
+    }
+    for _, reaction := range(tx.reactions) {
+      for _, post := range(revert.posts) {
+        if reaction.PostId == post.Id {
+          revert.reactions = append(revert.reactions, reaction)
+        }
+      }
+    }
+    p.rollback(revert)
+  }
+  if partial { return i18n.NewError(i18n.MsgErrorMoveIncomplete) }
+  return i18n.NewError(i18n.MsgErrorMoveFailed)
+}
+
+func (p *Plug) hasNewReplies(posts []*model.Post, since int64) (bool, error) {
+  known := make(map[string]bool, len(posts))
+  for _, post := range(posts) { known[post.Id] = true }
+
+  thread, err := p.getThreadPosts(posts[0].Id)
+  if err != nil { return false, err }
+  for _, post := range(thread) {
+    if !known[post.Id] && post.CreateAt > since { return true, nil }
+  }
+  return false, nil
+}